@@ -34,7 +34,7 @@ func AllowIPMiddleware(allowedIPs []string) router.Middleware {
 			}
 
 			if !isAllowed {
-				http.Error(w, "Forbidden", http.StatusForbidden)
+				r.Error(w, router.NewHTTPError(http.StatusForbidden, "ip_not_allowed", "Forbidden"))
 				return
 			}
 
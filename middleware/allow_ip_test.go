@@ -45,6 +45,11 @@ func TestAllowIPMiddleware(t *testing.T) {
 			if rr.Code != tt.wantStatus {
 				t.Errorf("got status %v, want %v", rr.Code, tt.wantStatus)
 			}
+
+			// Rejections are reported as problem details
+			if tt.wantStatus == http.StatusForbidden && rr.Header().Get("Content-Type") != ninaRouter.ProblemContentType {
+				t.Errorf("got content type %v, want %v", rr.Header().Get("Content-Type"), ninaRouter.ProblemContentType)
+			}
 		})
 	}
 }
@@ -1,14 +1,21 @@
 package middleware
 
-import "net/http"
+import (
+	"github.com/jonecoboy/nina/router"
+	"net/http"
+)
 
+// BasicAuthMiddleware lets through the requests carrying username and
+// password as basic auth credentials. Other requests get a 401 problem
+// document with a WWW-Authenticate challenge.
 func BasicAuthMiddleware(username, password string, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user, pass, ok := r.BasicAuth()
 
 		if !ok || user != username || pass != password {
 			w.Header().Set("WWW-Authenticate", `Basic realm="Please enter your username and password"`)
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			httpErr := router.NewHTTPError(http.StatusUnauthorized, "invalid_credentials", "")
+			router.WriteProblem(w, httpErr.Problem(&router.NinaRequest{Request: r}))
 			return
 		}
 
@@ -1,9 +1,12 @@
 package middleware
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	ninaRouter "github.com/jonecoboy/nina/router"
 )
 
 func TestBasicAuthMiddleware(t *testing.T) {
@@ -39,6 +42,23 @@ func TestBasicAuthMiddleware(t *testing.T) {
 			if rr.Code != tt.wantStatus {
 				t.Errorf("got status %v, want %v", rr.Code, tt.wantStatus)
 			}
+			if tt.wantStatus != http.StatusUnauthorized {
+				return
+			}
+
+			if rr.Header().Get("WWW-Authenticate") == "" {
+				t.Error("missing WWW-Authenticate challenge")
+			}
+			if got := rr.Header().Get("Content-Type"); got != ninaRouter.ProblemContentType {
+				t.Errorf("got content type %v, want %v", got, ninaRouter.ProblemContentType)
+			}
+			var problem ninaRouter.Problem
+			if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("invalid problem %v: %v", rr.Body.String(), err)
+			}
+			if problem.Status != http.StatusUnauthorized || problem.Instance != "/hello" || problem.Extensions["code"] != "invalid_credentials" {
+				t.Errorf("got problem %+v", problem)
+			}
 		})
 	}
 }
@@ -23,11 +23,11 @@ func BlockIPMiddleware(blockedIPs []string) router.Middleware {
 			for blockedIP := range blocked {
 				if strings.HasSuffix(blockedIP, "*") {
 					if strings.HasPrefix(ip, strings.TrimSuffix(blockedIP, "*")) {
-						http.Error(w, "Forbidden", http.StatusForbidden)
+						r.Error(w, router.NewHTTPError(http.StatusForbidden, "ip_blocked", "Forbidden"))
 						return
 					}
 				} else if ip == blockedIP {
-					http.Error(w, "Forbidden", http.StatusForbidden)
+					r.Error(w, router.NewHTTPError(http.StatusForbidden, "ip_blocked", "Forbidden"))
 					return
 				}
 			}
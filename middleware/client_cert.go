@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"context"
+	"crypto/x509"
+	"github.com/jonecoboy/nina/router"
+	"net/http"
+	"strings"
+)
+
+// ClientPrincipal is the identity of a client authenticated by its
+// certificate, see ClientCertMiddleware.
+type ClientPrincipal struct {
+	CommonName string
+	DNSNames   []string
+	Emails     []string
+	URIs       []string
+	// SPIFFEID is the spiffe:// URI SAN of the certificate, empty when it
+	// has none
+	SPIFFEID    string
+	Certificate *x509.Certificate
+}
+
+// Identities lists the names the principal is matched by in allowlists:
+// cn:, dns:, email: and uri: followed by the value. The SPIFFE ID is an uri.
+func (p *ClientPrincipal) Identities() []string {
+	var identities []string
+	if p.CommonName != "" {
+		identities = append(identities, "cn:"+p.CommonName)
+	}
+	for _, name := range p.DNSNames {
+		identities = append(identities, "dns:"+name)
+	}
+	for _, email := range p.Emails {
+		identities = append(identities, "email:"+email)
+	}
+	for _, uri := range p.URIs {
+		identities = append(identities, "uri:"+uri)
+	}
+	return identities
+}
+
+// Allowed reports whether one of the identities of the principal is in
+// allowed. Entries are cn:billing, dns:billing.internal, email:ops@corp,
+// uri:spiffe://mesh/ns/billing/sa/api, or a bare spiffe:// ID. A trailing *
+// matches any suffix, like uri:spiffe://mesh/ns/billing/*.
+func (p *ClientPrincipal) Allowed(allowed []string) bool {
+	identities := p.Identities()
+	for _, entry := range allowed {
+		if strings.HasPrefix(entry, "spiffe://") {
+			entry = "uri:" + entry
+		}
+		for _, identity := range identities {
+			if prefix, ok := strings.CutSuffix(entry, "*"); ok {
+				if strings.HasPrefix(identity, prefix) {
+					return true
+				}
+			} else if identity == entry {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// newClientPrincipal reads the identities of a certificate
+func newClientPrincipal(cert *x509.Certificate) *ClientPrincipal {
+	p := &ClientPrincipal{
+		CommonName:  cert.Subject.CommonName,
+		DNSNames:    cert.DNSNames,
+		Emails:      cert.EmailAddresses,
+		Certificate: cert,
+	}
+	for _, uri := range cert.URIs {
+		p.URIs = append(p.URIs, uri.String())
+		if uri.Scheme == "spiffe" && p.SPIFFEID == "" {
+			p.SPIFFEID = uri.String()
+		}
+	}
+	return p
+}
+
+type clientPrincipalKey struct{}
+
+// ClientPrincipalFromContext returns the principal stored by
+// ClientCertMiddleware.
+func ClientPrincipalFromContext(ctx context.Context) (*ClientPrincipal, bool) {
+	p, ok := ctx.Value(clientPrincipalKey{}).(*ClientPrincipal)
+	return p, ok
+}
+
+// ClientCertOptions configures ClientCertMiddleware.
+type ClientCertOptions struct {
+	// Roots verifies the client chains in the middleware, for servers that
+	// request client certificates without verifying them. When nil the
+	// chain must have been verified by the TLS server.
+	Roots *x509.CertPool
+	// Allow is the allowlist applied to every request, see
+	// ClientPrincipal.Allowed. Empty accepts any verified certificate.
+	Allow []string
+}
+
+// ClientCertMiddleware authenticates requests with the verified client
+// certificate of their TLS connection and stores the resulting
+// ClientPrincipal on the request context. Requests without a verified
+// certificate get a 401, certificates outside of the allowlist a 403. Use
+// AllowClientsMiddleware to restrict routes or groups further.
+func ClientCertMiddleware(opts ClientCertOptions) router.Middleware {
+	return func(next router.Handler) router.Handler {
+		return router.Handler(func(w http.ResponseWriter, r *router.NinaRequest) {
+			cert := verifiedClientCert(r, opts.Roots)
+			if cert == nil {
+				r.Error(w, router.NewHTTPError(http.StatusUnauthorized, "client_cert_required", "A verified client certificate is required"))
+				return
+			}
+			principal := newClientPrincipal(cert)
+			if len(opts.Allow) > 0 && !principal.Allowed(opts.Allow) {
+				r.Error(w, router.NewHTTPError(http.StatusForbidden, "client_cert_not_allowed", "Forbidden"))
+				return
+			}
+
+			r.SetContext(context.WithValue(r.Context(), clientPrincipalKey{}, principal))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// AllowClientsMiddleware lets through the requests whose ClientPrincipal is
+// in allowed, see ClientPrincipal.Allowed. It needs ClientCertMiddleware to
+// run first, on the router or the group.
+func AllowClientsMiddleware(allowed ...string) router.Middleware {
+	return func(next router.Handler) router.Handler {
+		return router.Handler(func(w http.ResponseWriter, r *router.NinaRequest) {
+			principal, ok := ClientPrincipalFromContext(r.Context())
+			if !ok {
+				r.Error(w, router.NewHTTPError(http.StatusUnauthorized, "client_cert_required", "A verified client certificate is required"))
+				return
+			}
+			if !principal.Allowed(allowed) {
+				r.Error(w, router.NewHTTPError(http.StatusForbidden, "client_cert_not_allowed", "Forbidden"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// verifiedClientCert returns the leaf certificate of the client once its
+// chain is verified, nil otherwise
+func verifiedClientCert(r *router.NinaRequest, roots *x509.CertPool) *x509.Certificate {
+	state := r.ConnectionState()
+	certs := r.UnverifiedPeerCertificates()
+	if state == nil || len(certs) == 0 {
+		return nil
+	}
+	if roots == nil {
+		if len(state.VerifiedChains) == 0 {
+			return nil
+		}
+		return state.VerifiedChains[0][0]
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return nil
+	}
+	return certs[0]
+}
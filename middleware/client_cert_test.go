@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jonecoboy/nina/internal/testcert"
+	ninaRouter "github.com/jonecoboy/nina/router"
+)
+
+// startMTLSServer serves nr over TLS asking clients for a certificate with
+// the given policy
+func startMTLSServer(t *testing.T, nr *ninaRouter.ServeMux, ca *testcert.Cert, clientAuth tls.ClientAuthType) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(nr)
+	server.TLS = &tls.Config{ClientAuth: clientAuth}
+	if clientAuth >= tls.VerifyClientCertIfGiven {
+		// Only the verifying servers advertise their CA, clients would not
+		// send a certificate of another issuer otherwise
+		server.TLS.ClientCAs = ca.Pool()
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func clientFor(server *httptest.Server, id *testcert.Cert) *http.Client {
+	client := server.Client()
+	transport := client.Transport.(*http.Transport).Clone()
+	if id != nil {
+		transport.TLSClientConfig.Certificates = []tls.Certificate{id.TLS()}
+	}
+	client.Transport = transport
+	return client
+}
+
+func TestClientCertMiddleware(t *testing.T) {
+	ca := testcert.New(t, nil, "mesh ca")
+	otherCA := testcert.New(t, nil, "other ca")
+
+	billing := testcert.New(t, ca, "billing", "billing.internal", "spiffe://mesh/ns/prod/sa/billing")
+	reports := testcert.New(t, ca, "reports", "spiffe://mesh/ns/prod/sa/reports")
+	staging := testcert.New(t, ca, "billing", "spiffe://mesh/ns/staging/sa/billing")
+	intruder := testcert.New(t, otherCA, "billing", "spiffe://mesh/ns/prod/sa/billing")
+
+	whoami := func(w http.ResponseWriter, r *ninaRouter.NinaRequest) {
+		p, _ := ClientPrincipalFromContext(r.Context())
+		io.WriteString(w, p.CommonName+" "+p.SPIFFEID)
+	}
+
+	tests := []struct {
+		name       string
+		clientAuth tls.ClientAuthType
+		opts       ClientCertOptions
+		target     string
+		client     *testcert.Cert
+		wantStatus int
+		wantBody   string
+		wantCode   string
+	}{
+		{"Verified by the server", tls.VerifyClientCertIfGiven, ClientCertOptions{}, "/whoami", billing, http.StatusOK, "billing spiffe://mesh/ns/prod/sa/billing", ""},
+		{"No certificate", tls.VerifyClientCertIfGiven, ClientCertOptions{}, "/whoami", nil, http.StatusUnauthorized, "", "client_cert_required"},
+		{"Global allowlist", tls.VerifyClientCertIfGiven, ClientCertOptions{Allow: []string{"spiffe://mesh/ns/prod/*"}}, "/whoami", staging, http.StatusForbidden, "", "client_cert_not_allowed"},
+		{"Group allowlist by SPIFFE ID", tls.VerifyClientCertIfGiven, ClientCertOptions{}, "/billing/invoices", billing, http.StatusOK, "billing spiffe://mesh/ns/prod/sa/billing", ""},
+		{"Group allowlist rejects", tls.VerifyClientCertIfGiven, ClientCertOptions{}, "/billing/invoices", reports, http.StatusForbidden, "", "client_cert_not_allowed"},
+		{"Route allowlist by DNS name", tls.VerifyClientCertIfGiven, ClientCertOptions{}, "/billing/export", billing, http.StatusOK, "billing spiffe://mesh/ns/prod/sa/billing", ""},
+		{"Route allowlist rejects", tls.VerifyClientCertIfGiven, ClientCertOptions{}, "/billing/export", staging, http.StatusForbidden, "", "client_cert_not_allowed"},
+		{"Verified by the middleware", tls.RequireAnyClientCert, ClientCertOptions{Roots: ca.Pool()}, "/whoami", reports, http.StatusOK, "reports spiffe://mesh/ns/prod/sa/reports", ""},
+		{"Unknown issuer", tls.RequireAnyClientCert, ClientCertOptions{Roots: ca.Pool()}, "/whoami", intruder, http.StatusUnauthorized, "", "client_cert_required"},
+		{"Unverified chain", tls.RequireAnyClientCert, ClientCertOptions{}, "/whoami", billing, http.StatusUnauthorized, "", "client_cert_required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nr := ninaRouter.NewRouter()
+			nr.Use(ClientCertMiddleware(tt.opts))
+			nr.GET("/whoami", whoami, nil)
+			group := nr.GROUP("/billing", []ninaRouter.Middleware{AllowClientsMiddleware("uri:spiffe://mesh/ns/prod/sa/billing")}, nil)
+			group.GET("/invoices", whoami, nil)
+			group.GET("/export", whoami, []ninaRouter.Middleware{AllowClientsMiddleware("dns:billing.*")})
+
+			server := startMTLSServer(t, nr, ca, tt.clientAuth)
+			res, err := clientFor(server, tt.client).Get(server.URL + tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			body, _ := io.ReadAll(res.Body)
+
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("got status %v, want %v: %s", res.StatusCode, tt.wantStatus, body)
+			}
+			if tt.wantBody != "" && string(body) != tt.wantBody {
+				t.Errorf("got body %s, want %v", body, tt.wantBody)
+			}
+			if tt.wantCode != "" && !strings.Contains(string(body), `"code":"`+tt.wantCode+`"`) {
+				t.Errorf("got body %s, want code %v", body, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestAllowClientsMiddlewareWithoutPrincipal(t *testing.T) {
+	nr := ninaRouter.NewRouter()
+	nr.GET("/admin", func(w http.ResponseWriter, r *ninaRouter.NinaRequest) {}, []ninaRouter.Middleware{AllowClientsMiddleware("cn:admin")})
+
+	rr := httptest.NewRecorder()
+	nr.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin", nil))
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("got status %v, want %v", rr.Code, http.StatusUnauthorized)
+	}
+}
@@ -21,7 +21,7 @@ func AllowedContentTypeMiddleware(allowedTypes []string) router.Middleware {
 					return
 				}
 			}
-			http.Error(w, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+			r.Error(w, router.NewHTTPError(http.StatusUnsupportedMediaType, "unsupported_media_type", "Unsupported Media Type"))
 		})
 	}
 }
@@ -17,7 +17,7 @@ func ContentTypeBlockMiddleware(blockedTypes []string) router.Middleware {
 			contentType := r.Header.Get("Content-Type")
 			for blockedType := range blocked {
 				if strings.HasPrefix(contentType, blockedType) {
-					http.Error(w, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+					r.Error(w, router.NewHTTPError(http.StatusUnsupportedMediaType, "unsupported_media_type", "Unsupported Media Type"))
 					return
 				}
 			}
@@ -23,7 +23,7 @@ func CSRFValidateMiddleware(next ninaRouter.Handler) ninaRouter.Handler {
 		// Validate the CSRF token
 		token := r.Header.Get(csrfTokenHeader)
 		if token != validToken {
-			http.Error(w, "Forbidden", http.StatusForbidden)
+			r.Error(w, ninaRouter.NewHTTPError(http.StatusForbidden, "invalid_csrf_token", "Forbidden"))
 			return
 		}
 		next(w, r)
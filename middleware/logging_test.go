@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
 )
@@ -30,7 +31,7 @@ func TestLoggingMiddleware(t *testing.T) {
 	// Capture the log output
 	var logBuf bytes.Buffer
 	log.SetOutput(&logBuf)
-	defer log.SetOutput(nil)
+	defer log.SetOutput(os.Stderr)
 
 	// Serve the request
 	nr.ServeHTTP(rr, req)
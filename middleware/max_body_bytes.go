@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"github.com/jonecoboy/nina/router"
+	"net/http"
+)
+
+// MaxBodyBytesMiddleware rejects request bodies larger than n bytes with 413,
+// overriding the router MaxBodyBytes for a group or a route. n <= 0 removes
+// the limit.
+func MaxBodyBytesMiddleware(n int64) router.Middleware {
+	return func(next router.Handler) router.Handler {
+		return router.Handler(func(w http.ResponseWriter, r *router.NinaRequest) {
+			r.LimitBody(w, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
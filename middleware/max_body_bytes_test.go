@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	ninaRouter "github.com/jonecoboy/nina/router"
+)
+
+func TestMaxBodyBytesMiddleware(t *testing.T) {
+	nr := ninaRouter.NewRouter()
+	nr.MaxBodyBytes = 16
+
+	bodyHandler := func(w http.ResponseWriter, r *ninaRouter.NinaRequest) {
+		body, err := r.GetBody()
+		if err != nil {
+			r.Error(w, err)
+			return
+		}
+		w.Write([]byte(body["name"].(string)))
+	}
+
+	nr.POST("/default", bodyHandler, nil)
+	nr.POST("/larger", bodyHandler, []ninaRouter.Middleware{MaxBodyBytesMiddleware(64)})
+	nr.POST("/unlimited", bodyHandler, []ninaRouter.Middleware{MaxBodyBytesMiddleware(0)})
+	nr.GROUP("/small", []ninaRouter.Middleware{MaxBodyBytesMiddleware(4)}, nil).POST("/route", bodyHandler, nil)
+
+	short := `{"name":"nina"}`
+	long := `{"name":"` + strings.Repeat("n", 32) + `"}`
+
+	tests := []struct {
+		name       string
+		url        string
+		body       string
+		wantStatus int
+	}{
+		{"Router limit - within", "/default", short, http.StatusOK},
+		{"Router limit - over", "/default", long, http.StatusRequestEntityTooLarge},
+		{"Route limit - larger", "/larger", long, http.StatusOK},
+		{"Route limit - removed", "/unlimited", long + strings.Repeat(" ", 64), http.StatusOK},
+		{"Group limit - smaller", "/small/route", short, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.url, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+
+			nr.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %v, want %v: %v", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantStatus == http.StatusRequestEntityTooLarge && !strings.Contains(rr.Body.String(), `"code":"body_too_large"`) {
+				t.Errorf("got body %v, want code body_too_large", rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,403 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/jonecoboy/nina/router"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OpenAPIValidatorOptions configures OpenAPIValidatorMiddleware.
+type OpenAPIValidatorOptions struct {
+	// BasePath is removed from the request path before looking up the
+	// operation, usually the path of the server URL of the document
+	BasePath string
+	// ValidateResponses buffers every response and checks its status and JSON
+	// body against the operation. Mismatches are answered with a 500 listing
+	// them, it is meant for development.
+	ValidateResponses bool
+}
+
+// OpenAPIValidatorMiddleware loads the OpenAPI 3 JSON document specFile and
+// validates the path, query and header parameters and the JSON body of the
+// requests against their operation. Invalid requests get a 422 listing every
+// failure as path.id, query.limit, header.X-Trace-Id or body.items[0].name,
+// bodies of an undocumented media type a 415. Requests matching no operation
+// are left to the router. multipart/form-data bodies are only checked against
+// the documented media types, their parts are left to the handler. YAML specs
+// are rejected, convert them to JSON.
+func OpenAPIValidatorMiddleware(specFile string, opts OpenAPIValidatorOptions) (router.Middleware, error) {
+	doc, err := router.LoadOpenAPI(specFile)
+	if err != nil {
+		return nil, err
+	}
+	v := &openAPIValidator{doc: doc, opts: opts}
+	for template, item := range doc.Paths {
+		path, err := compileOpenAPIPath(template, item)
+		if err != nil {
+			return nil, err
+		}
+		v.paths = append(v.paths, path)
+	}
+	// Paths with more literal characters win, /users/me before /users/{id}
+	sort.SliceStable(v.paths, func(i, j int) bool {
+		if v.paths[i].literals != v.paths[j].literals {
+			return v.paths[i].literals > v.paths[j].literals
+		}
+		return v.paths[i].template < v.paths[j].template
+	})
+
+	return func(next router.Handler) router.Handler {
+		return router.Handler(func(w http.ResponseWriter, r *router.NinaRequest) {
+			op, params := v.operation(r)
+			if op == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if err := v.validateRequest(r, op, params); err != nil {
+				r.Error(w, err)
+				return
+			}
+			if !opts.ValidateResponses {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rec := &responseRecorder{header: http.Header{}}
+			next.ServeHTTP(rec, r)
+			if err := v.validateResponse(op, rec); err != nil {
+				log.Printf("openapi: %s %s: %v", r.Method, r.URL.Path, err)
+				r.Error(w, router.NewHTTPError(http.StatusInternalServerError, "response_invalid", "Response does not match the API specification").
+					WithDetails(err).Wrap(err))
+				return
+			}
+			rec.flush(w)
+		})
+	}, nil
+}
+
+type openAPIValidator struct {
+	doc   *router.OpenAPIDocument
+	opts  OpenAPIValidatorOptions
+	paths []*openAPIPath
+}
+
+// openAPIPath is a path template of the document compiled to a regexp
+type openAPIPath struct {
+	template string
+	re       *regexp.Regexp
+	names    []string
+	literals int
+	item     *router.PathItem
+}
+
+var templateParam = regexp.MustCompile(`\{([^{}/]+)\}`)
+
+func compileOpenAPIPath(template string, item *router.PathItem) (*openAPIPath, error) {
+	path := &openAPIPath{template: template, item: item}
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	last := 0
+	for _, loc := range templateParam.FindAllStringSubmatchIndex(template, -1) {
+		literal := template[last:loc[0]]
+		pattern.WriteString(regexp.QuoteMeta(literal))
+		pattern.WriteString("([^/]+)")
+		path.literals += len(literal)
+		path.names = append(path.names, template[loc[2]:loc[3]])
+		last = loc[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(template[last:]) + "$")
+	path.literals += len(template) - last
+
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return nil, errors.New("openapi: invalid path " + template)
+	}
+	path.re = re
+	return path, nil
+}
+
+// operation finds the operation of the request and its path params
+func (v *openAPIValidator) operation(r *router.NinaRequest) (*router.Operation, map[string]string) {
+	path, ok := strings.CutPrefix(r.URL.Path, strings.TrimSuffix(v.opts.BasePath, "/"))
+	if !ok {
+		return nil, nil
+	}
+	for _, p := range v.paths {
+		match := p.re.FindStringSubmatch(path)
+		if match == nil {
+			continue
+		}
+		op := (*p.item)[strings.ToLower(r.Method)]
+		if op == nil {
+			// Another method of the path, the router answers 405
+			return nil, nil
+		}
+		params := make(map[string]string, len(p.names))
+		for i, name := range p.names {
+			params[name] = match[i+1]
+		}
+		return op, params
+	}
+	return nil, nil
+}
+
+func (v *openAPIValidator) validateRequest(r *router.NinaRequest, op *router.Operation, pathParams map[string]string) error {
+	var errs router.ValidationErrors
+	fail := func(err error) {
+		var fieldErrs router.ValidationErrors
+		if errors.As(err, &fieldErrs) {
+			errs = append(errs, fieldErrs...)
+		}
+	}
+
+	query := r.URL.Query()
+	for _, param := range op.Parameters {
+		var values []string
+		switch param.In {
+		case "path":
+			if value, ok := pathParams[param.Name]; ok {
+				values = []string{value}
+			}
+		case "query":
+			values = query[param.Name]
+		case "header":
+			values = r.Header.Values(param.Name)
+		case "cookie":
+			if cookie, err := r.Cookie(param.Name); err == nil {
+				values = []string{cookie.Value}
+			}
+		}
+
+		field := param.In + "." + param.Name
+		if len(values) == 0 {
+			if param.Required {
+				errs = append(errs, &router.FieldError{Field: field, Rule: "required", Message: "is required"})
+			}
+			continue
+		}
+		schema := v.resolve(param.Schema)
+		value, ok := paramValue(schema, param.In, values)
+		if !ok {
+			errs = append(errs, &router.FieldError{Field: field, Rule: "type", Param: schema.Type, Message: "must be of type " + schema.Type})
+			continue
+		}
+		fail(v.doc.ValidateValue(schema, value, field))
+	}
+
+	if op.RequestBody != nil && isMultipart(r.Header.Get("Content-Type")) {
+		// Buffering the uploads to check them against the schema would bypass
+		// the multipart limits, the parts are left to the handler
+		if _, content := findContent(op.RequestBody.Content, r.Header.Get("Content-Type")); content == nil {
+			return router.NewHTTPError(http.StatusUnsupportedMediaType, "media_type_not_allowed", "").
+				WithDetails(map[string]interface{}{"allowed": contentTypes(op.RequestBody.Content)})
+		}
+	} else if op.RequestBody != nil {
+		body, err := r.RawBody()
+		switch {
+		case errors.Is(err, router.ErrBodyStreamed):
+			// Left to the handler
+		case err != nil:
+			return err
+		case len(body) == 0:
+			if op.RequestBody.Required {
+				errs = append(errs, &router.FieldError{Field: "body", Rule: "required", Message: "is required"})
+			}
+		default:
+			mediaType, content := findContent(op.RequestBody.Content, r.Header.Get("Content-Type"))
+			if content == nil {
+				return router.NewHTTPError(http.StatusUnsupportedMediaType, "media_type_not_allowed", "").
+					WithDetails(map[string]interface{}{"allowed": contentTypes(op.RequestBody.Content)})
+			}
+			if content.Schema != nil && isJSON(mediaType) {
+				value, err := decodeJSON(body)
+				if err != nil {
+					return router.NewHTTPError(http.StatusBadRequest, "invalid_json", "Invalid JSON").Wrap(err)
+				}
+				fail(v.doc.ValidateValue(content.Schema, value, "body"))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (v *openAPIValidator) validateResponse(op *router.Operation, rec *responseRecorder) error {
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	code := strconv.Itoa(status)
+	response := op.Responses[code]
+	if response == nil {
+		response = op.Responses[code[:1]+"XX"]
+	}
+	if response == nil {
+		response = op.Responses["default"]
+	}
+	if response == nil {
+		return router.ValidationErrors{{Field: "response", Rule: "status", Param: code, Message: "status " + code + " is not documented"}}
+	}
+	if len(response.Content) == 0 || rec.body.Len() == 0 {
+		return nil
+	}
+
+	mediaType, content := findContent(response.Content, rec.header.Get("Content-Type"))
+	if content == nil {
+		return router.ValidationErrors{{Field: "response", Rule: "contentType", Param: mediaType, Message: "media type " + mediaType + " is not documented"}}
+	}
+	if content.Schema == nil || !isJSON(mediaType) {
+		return nil
+	}
+	value, err := decodeJSON(rec.body.Bytes())
+	if err != nil {
+		return router.ValidationErrors{{Field: "response", Rule: "json", Message: "must be valid JSON"}}
+	}
+	return v.doc.ValidateValue(content.Schema, value, "response")
+}
+
+// resolve follows the reference of a parameter schema so its type is known
+// before converting the raw value
+func (v *openAPIValidator) resolve(schema *router.Schema) *router.Schema {
+	for i := 0; schema != nil && schema.Ref != "" && i < 16; i++ {
+		schema = v.doc.Schema(schema.Ref)
+	}
+	if schema == nil {
+		return &router.Schema{}
+	}
+	return schema
+}
+
+// paramValue converts the raw values of a parameter to the JSON value its
+// schema expects. Arrays are repeated query params or a comma separated
+// list elsewhere.
+func paramValue(schema *router.Schema, in string, values []string) (interface{}, bool) {
+	if schema.Type == "array" {
+		if in != "query" && len(values) == 1 {
+			values = strings.Split(values[0], ",")
+		}
+		items := &router.Schema{}
+		if schema.Items != nil {
+			items = schema.Items
+		}
+		array := make([]interface{}, len(values))
+		for i, raw := range values {
+			value, ok := scalarValue(items.Type, raw)
+			if !ok {
+				return nil, false
+			}
+			array[i] = value
+		}
+		return array, true
+	}
+	return scalarValue(schema.Type, values[0])
+}
+
+func scalarValue(schemaType, raw string) (interface{}, bool) {
+	switch schemaType {
+	case "integer", "number":
+		n, err := strconv.ParseFloat(raw, 64)
+		return n, err == nil
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		return b, err == nil
+	}
+	return raw, true
+}
+
+// findContent picks the media type of content matching contentType, exact
+// matches first then type/* and */*
+func findContent(content map[string]*router.MediaType, contentType string) (string, *router.MediaType) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	if media, ok := content[mediaType]; ok {
+		return mediaType, media
+	}
+	if major, _, ok := strings.Cut(mediaType, "/"); ok {
+		if media, ok := content[major+"/*"]; ok {
+			return mediaType, media
+		}
+	}
+	return mediaType, content["*/*"]
+}
+
+func contentTypes(content map[string]*router.MediaType) []string {
+	types := make([]string, 0, len(content))
+	for mediaType := range content {
+		types = append(types, mediaType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func isMultipart(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "multipart/form-data"
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// decodeJSON decodes a single JSON value, numbers are kept exact. Anything
+// but whitespace after the value is an error.
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return value, nil
+}
+
+// responseRecorder holds the response until it has been validated
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.body.Write(b)
+}
+
+// flush sends the recorded response to w, headers included
+func (rec *responseRecorder) flush(w http.ResponseWriter) {
+	for key, values := range rec.header {
+		w.Header()[key] = values
+	}
+	if rec.status != 0 {
+		w.WriteHeader(rec.status)
+	}
+	w.Write(rec.body.Bytes())
+}
@@ -0,0 +1,243 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ninaRouter "github.com/jonecoboy/nina/router"
+)
+
+const petstoreSpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "Pets", "version": "1.0.0"},
+  "paths": {
+    "/pets/{id}": {
+      "parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "integer", "minimum": 1}}],
+      "get": {
+        "parameters": [{"name": "X-Trace-Id", "in": "header", "required": true, "schema": {"type": "string", "format": "uuid"}}],
+        "responses": {"200": {"description": "OK", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Pet"}}}}}
+      }
+    },
+    "/pets": {
+      "get": {
+        "parameters": [
+          {"name": "limit", "in": "query", "schema": {"type": "integer", "maximum": 100}},
+          {"name": "tag", "in": "query", "schema": {"type": "array", "items": {"type": "string", "enum": ["cat", "dog"]}}}
+        ],
+        "responses": {"200": {"description": "OK"}}
+      },
+      "post": {
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Pet"}}}},
+        "responses": {"201": {"description": "Created"}}
+      }
+    },
+    "/uploads": {
+      "post": {
+        "requestBody": {"required": true, "content": {"multipart/form-data": {"schema": {"type": "object", "required": ["file"]}}}},
+        "responses": {"201": {"description": "Created"}}
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Pet": {
+        "type": "object",
+        "required": ["name"],
+        "additionalProperties": false,
+        "properties": {
+          "id": {"type": "integer"},
+          "name": {"type": "string", "minLength": 2},
+          "tags": {"type": "array", "maxItems": 2, "items": {"type": "string"}},
+          "owner": {"type": ["string", "null"], "format": "email"}
+        }
+      }
+    }
+  }
+}`
+
+func writeSpec(t *testing.T, spec string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "openapi.json")
+	if err := os.WriteFile(path, []byte(spec), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOpenAPIValidatorMiddleware(t *testing.T) {
+	validator, err := OpenAPIValidatorMiddleware(writeSpec(t, petstoreSpec), OpenAPIValidatorOptions{BasePath: "/api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nr := ninaRouter.NewRouter()
+	nr.Use(validator)
+	ok := func(w http.ResponseWriter, r *ninaRouter.NinaRequest) {
+		w.Write([]byte("ok"))
+	}
+	nr.GET("/api/pets/{id}", ok, nil)
+	nr.GET("/api/pets", ok, nil)
+	nr.POST("/api/pets", ok, nil)
+	nr.GET("/api/health", ok, nil)
+	nr.POST("/api/uploads", func(w http.ResponseWriter, r *ninaRouter.NinaRequest) {
+		header, err := r.FormFile("file")
+		if err != nil {
+			r.Error(w, err)
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			r.Error(w, err)
+			return
+		}
+		defer file.Close()
+		io.Copy(w, file)
+	}, nil)
+
+	trace := "0b9e3c1a-5d6f-4e8a-9c2b-7a1d3e5f7b9c"
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		header     map[string]string
+		body       string
+		wantStatus int
+		wantFields []string
+		wantCode   string
+	}{
+		{"Valid path and header", "GET", "/api/pets/7", map[string]string{"X-Trace-Id": trace}, "", http.StatusOK, nil, ""},
+		{"Path param not an integer", "GET", "/api/pets/abc", map[string]string{"X-Trace-Id": trace}, "", http.StatusUnprocessableEntity, []string{"path.id"}, ""},
+		{"Path param below minimum", "GET", "/api/pets/0", map[string]string{"X-Trace-Id": trace}, "", http.StatusUnprocessableEntity, []string{"path.id"}, ""},
+		{"Missing header", "GET", "/api/pets/7", nil, "", http.StatusUnprocessableEntity, []string{"header.X-Trace-Id"}, ""},
+		{"Invalid header format", "GET", "/api/pets/7", map[string]string{"X-Trace-Id": "nope"}, "", http.StatusUnprocessableEntity, []string{"header.X-Trace-Id"}, ""},
+		{"Valid query", "GET", "/api/pets?limit=10&tag=cat&tag=dog", nil, "", http.StatusOK, nil, ""},
+		{"Invalid query", "GET", "/api/pets?limit=500&tag=cow", nil, "", http.StatusUnprocessableEntity, []string{"query.limit", "query.tag[0]"}, ""},
+		{"Valid body", "POST", "/api/pets", map[string]string{"Content-Type": "application/json"}, `{"name":"Rex","tags":["good"],"owner":null}`, http.StatusOK, nil, ""},
+		{"Invalid body", "POST", "/api/pets", map[string]string{"Content-Type": "application/json"}, `{"name":"R","id":1.5,"tags":["a","b","c"],"color":"red","owner":"x"}`, http.StatusUnprocessableEntity, []string{"body.color", "body.id", "body.name", "body.owner", "body.tags"}, ""},
+		{"Missing required property", "POST", "/api/pets", map[string]string{"Content-Type": "application/json"}, `{}`, http.StatusUnprocessableEntity, []string{"body.name"}, ""},
+		{"Body is not an object", "POST", "/api/pets", map[string]string{"Content-Type": "application/json"}, `["Rex"]`, http.StatusUnprocessableEntity, []string{"body"}, ""},
+		{"Missing body", "POST", "/api/pets", map[string]string{"Content-Type": "application/json"}, "", http.StatusUnprocessableEntity, []string{"body"}, ""},
+		{"Malformed JSON", "POST", "/api/pets", map[string]string{"Content-Type": "application/json"}, `{"name":`, http.StatusBadRequest, nil, "invalid_json"},
+		{"Trailing JSON", "POST", "/api/pets", map[string]string{"Content-Type": "application/json"}, `{"name":"rex"} {"name":"fido"}`, http.StatusBadRequest, nil, "invalid_json"},
+		{"Undocumented media type", "POST", "/api/pets", map[string]string{"Content-Type": "text/plain"}, "name=Rex", http.StatusUnsupportedMediaType, nil, "media_type_not_allowed"},
+		{"Multipart left to the handler", "POST", "/api/uploads", map[string]string{"Content-Type": "multipart/form-data; boundary=b"}, "--b\r\nContent-Disposition: form-data; name=\"file\"; filename=\"a.txt\"\r\n\r\nok\r\n--b--\r\n", http.StatusOK, nil, ""},
+		{"Undocumented upload media type", "POST", "/api/uploads", map[string]string{"Content-Type": "application/json"}, `{}`, http.StatusUnsupportedMediaType, nil, "media_type_not_allowed"},
+		{"Unknown operation", "GET", "/api/health", nil, "", http.StatusOK, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			for key, value := range tt.header {
+				req.Header.Set(key, value)
+			}
+			rr := httptest.NewRecorder()
+			nr.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("got status %v, want %v: %v", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantCode != "" && !strings.Contains(rr.Body.String(), `"code":"`+tt.wantCode+`"`) {
+				t.Errorf("got body %v, want code %v", rr.Body.String(), tt.wantCode)
+			}
+			if tt.wantFields == nil {
+				return
+			}
+
+			var problem struct {
+				Details []ninaRouter.FieldError `json:"details"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			var fields []string
+			for _, fieldErr := range problem.Details {
+				fields = append(fields, fieldErr.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.wantFields, ",") {
+				t.Errorf("got fields %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestOpenAPIValidatorResponses(t *testing.T) {
+	validator, err := OpenAPIValidatorMiddleware(writeSpec(t, petstoreSpec), OpenAPIValidatorOptions{ValidateResponses: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantStatus int
+	}{
+		{"Documented response", http.StatusOK, `{"id":7,"name":"Rex"}`, http.StatusOK},
+		{"Invalid body", http.StatusOK, `{"id":"seven"}`, http.StatusInternalServerError},
+		{"Undocumented status", http.StatusTeapot, `{}`, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nr := ninaRouter.NewRouter()
+			nr.GET("/pets/{id}", func(w http.ResponseWriter, r *ninaRouter.NinaRequest) {
+				w.Header().Set("Location", "/pets/7")
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}, []ninaRouter.Middleware{validator})
+
+			req := httptest.NewRequest("GET", "/pets/7", nil)
+			req.Header.Set("X-Trace-Id", "0b9e3c1a-5d6f-4e8a-9c2b-7a1d3e5f7b9c")
+			rr := httptest.NewRecorder()
+			nr.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("got status %v, want %v: %v", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK && rr.Body.String() != tt.body {
+				t.Errorf("got body %v, want %v", rr.Body.String(), tt.body)
+			}
+			if location := rr.Header().Get("Location"); (location != "") != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("got Location %q with status %v", location, rr.Code)
+			}
+			if tt.wantStatus != http.StatusOK && !strings.Contains(rr.Body.String(), `"code":"response_invalid"`) {
+				t.Errorf("got body %v, want response_invalid", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestOpenAPIValidatorLoadErrors(t *testing.T) {
+	yamlSpec := filepath.Join(t.TempDir(), "openapi.yaml")
+	if err := os.WriteFile(yamlSpec, []byte("openapi: 3.1.0\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{"Missing file", filepath.Join(t.TempDir(), "missing.json"), "no such file"},
+		{"Invalid JSON", writeSpec(t, `{"openapi":`), "unexpected end of JSON input"},
+		{"Swagger 2", writeSpec(t, `{"swagger":"2.0","paths":{}}`), "unsupported version"},
+		{"YAML spec", yamlSpec, "only JSON specs are supported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := OpenAPIValidatorMiddleware(tt.path, OpenAPIValidatorOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"fmt"
 	"github.com/jonecoboy/nina/router"
 	"log"
 	"net/http"
@@ -20,7 +21,7 @@ func RecoverFromPanicMiddleware(next router.Handler) router.Handler {
 				}
 
 				// Return HTTP 500 status
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				r.Error(w, router.NewHTTPError(http.StatusInternalServerError, "panic", "").Wrap(fmt.Errorf("panic: %v", err)))
 			}
 		}()
 
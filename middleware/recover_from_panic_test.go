@@ -1,10 +1,10 @@
 package middleware
 
 import (
+	"encoding/json"
 	"github.com/jonecoboy/nina/router"
 	"net/http"
 	"net/http/httptest"
-	"strings"
 	"testing"
 )
 
@@ -37,8 +37,15 @@ func TestRecoverFromPanicMiddleware(t *testing.T) {
 	}
 
 	// Check the response body
-	expectedBody := http.StatusText(http.StatusInternalServerError)
-	if body := rr.Body.String(); strings.TrimSpace(body) != expectedBody {
-		t.Errorf("handler returned unexpected body: got %v want %v", body, expectedBody)
+	if contentType := rr.Header().Get("Content-Type"); contentType != router.ProblemContentType {
+		t.Errorf("handler returned wrong content type: got %v want %v", contentType, router.ProblemContentType)
+	}
+	var body router.Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("handler returned invalid JSON body %q: %v", rr.Body.String(), err)
+	}
+	expectedTitle := http.StatusText(http.StatusInternalServerError)
+	if body.Title != expectedTitle || body.Status != http.StatusInternalServerError {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expectedTitle)
 	}
 }
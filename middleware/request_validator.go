@@ -5,19 +5,16 @@ import (
 	"net/http"
 )
 
-func RequestValidatorMiddleware(validationMap map[string]string) router.Middleware {
+// RequestValidatorMiddleware binds every request into a fresh value returned by
+// newTarget, validates it against its validate struct tags and exposes it as
+// r.Validated. Failing requests get a 422 listing every invalid field.
+func RequestValidatorMiddleware(newTarget func() interface{}) router.Middleware {
 	return func(next router.Handler) router.Handler {
 		return router.Handler(func(w http.ResponseWriter, r *router.NinaRequest) {
-			validatedData := make(map[string]string)
-			for key, value := range validationMap {
-				if r.FormValue(key) == value {
-					validatedData[key] = value
-				} else {
-					http.Error(w, "Invalid request parameters", http.StatusBadRequest)
-					return
-				}
+			if err := r.Validate(newTarget()); err != nil {
+				r.Error(w, err)
+				return
 			}
-			r.ValidatedData = validatedData
 			next.ServeHTTP(w, r)
 		})
 	}
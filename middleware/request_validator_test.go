@@ -1,27 +1,34 @@
 package middleware
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	ninaRouter "github.com/jonecoboy/nina/router"
 )
 
+type signupRequest struct {
+	User     string `query:"user" validate:"required,min=3,max=16,alphanum"`
+	Password string `query:"password" validate:"required,min=4"`
+	Email    string `query:"email" validate:"omitempty,email"`
+	Role     string `query:"role" validate:"omitempty,oneof=admin member"`
+	Age      int    `query:"age" validate:"omitempty,gte=18"`
+}
+
 func TestRequestValidatorMiddleware(t *testing.T) {
-	validationMap := map[string]string{
-		"user":     "admin",
-		"password": "1234",
-	}
-	middleware := RequestValidatorMiddleware(validationMap)
+	middleware := RequestValidatorMiddleware(func() interface{} { return &signupRequest{} })
 
 	// Create a new router
 	nr := ninaRouter.NewRouter()
 
 	// Define a simple handler
 	helloHandler := func(w http.ResponseWriter, r *ninaRouter.NinaRequest) {
+		req := r.Validated.(*signupRequest)
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Hello, World!"))
+		w.Write([]byte("Hello, " + req.User + "!"))
 	}
 
 	// Register the route with the handler and middleware
@@ -31,12 +38,15 @@ func TestRequestValidatorMiddleware(t *testing.T) {
 		name       string
 		formData   map[string]string
 		wantStatus int
+		wantFields []string
 	}{
-		{"Valid Request", map[string]string{"user": "admin", "password": "1234"}, http.StatusOK},
-		{"Invalid User", map[string]string{"user": "invalid", "password": "1234"}, http.StatusBadRequest},
-		{"Invalid Password", map[string]string{"user": "admin", "password": "wrong"}, http.StatusBadRequest},
-		{"Missing User", map[string]string{"password": "1234"}, http.StatusBadRequest},
-		{"Missing Password", map[string]string{"user": "admin"}, http.StatusBadRequest},
+		{"Valid Request", map[string]string{"user": "admin", "password": "1234"}, http.StatusOK, nil},
+		{"Short User", map[string]string{"user": "ad", "password": "1234"}, http.StatusUnprocessableEntity, []string{"user"}},
+		{"Short Password", map[string]string{"user": "admin", "password": "12"}, http.StatusUnprocessableEntity, []string{"password"}},
+		{"Missing User", map[string]string{"password": "1234"}, http.StatusUnprocessableEntity, []string{"user"}},
+		{"Missing Password", map[string]string{"user": "admin"}, http.StatusUnprocessableEntity, []string{"password"}},
+		{"Every field invalid", map[string]string{"user": "a-b", "email": "nope", "role": "root", "age": "12"}, http.StatusUnprocessableEntity, []string{"user", "password", "email", "role", "age"}},
+		{"Unparsable field", map[string]string{"user": "admin", "password": "1234", "age": "old"}, http.StatusBadRequest, nil},
 	}
 
 	for _, tt := range tests {
@@ -54,6 +64,23 @@ func TestRequestValidatorMiddleware(t *testing.T) {
 			if rr.Code != tt.wantStatus {
 				t.Errorf("got status %v, want %v", rr.Code, tt.wantStatus)
 			}
+
+			if tt.wantFields == nil {
+				return
+			}
+			var body struct {
+				Details []ninaRouter.FieldError `json:"details"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid error body %q: %v", rr.Body.String(), err)
+			}
+			var fields []string
+			for _, fieldErr := range body.Details {
+				fields = append(fields, fieldErr.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.wantFields, ",") {
+				t.Errorf("got failing fields %v, want %v", fields, tt.wantFields)
+			}
 		})
 	}
 }
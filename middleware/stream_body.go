@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"github.com/jonecoboy/nina/router"
+	"net/http"
+)
+
+// StreamBodyMiddleware hands r.Body to the handler untouched, for uploads and
+// NDJSON streams that must not be buffered. GetBody returns
+// router.ErrBodyStreamed on these routes.
+func StreamBodyMiddleware() router.Middleware {
+	return func(next router.Handler) router.Handler {
+		return router.Handler(func(w http.ResponseWriter, r *router.NinaRequest) {
+			r.StreamBody()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
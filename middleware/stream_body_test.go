@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	ninaRouter "github.com/jonecoboy/nina/router"
+)
+
+func TestStreamBodyMiddleware(t *testing.T) {
+	nr := ninaRouter.NewRouter()
+	nr.MaxBodyBytes = 8
+
+	nr.POST("/events/{topic}", func(w http.ResponseWriter, r *ninaRouter.NinaRequest) {
+		if _, err := r.GetBody(); !errors.Is(err, ninaRouter.ErrBodyStreamed) {
+			t.Errorf("got %v, want ErrBodyStreamed", err)
+		}
+		var params struct {
+			Topic string `uri:"topic"`
+		}
+		if err := r.Bind(&params); err != nil {
+			r.Error(w, err)
+			return
+		}
+
+		lines := 0
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			lines++
+		}
+		fmt.Fprintf(w, "%s:%d", params.Topic, lines)
+	}, []ninaRouter.Middleware{StreamBodyMiddleware()})
+
+	body := strings.Repeat(`{"event":"click"}`+"\n", 3)
+	req := httptest.NewRequest("POST", "/events/ui", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	rr := httptest.NewRecorder()
+
+	nr.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("got status %v, want %v", rr.Code, http.StatusOK)
+	}
+	if want := "ui:3"; rr.Body.String() != want {
+		t.Errorf("got body %v, want %v", rr.Body.String(), want)
+	}
+}
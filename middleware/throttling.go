@@ -54,7 +54,7 @@ func ThrottlingMiddleware(rate time.Duration, burst int) router.Middleware {
 				client.tokens--
 				next.ServeHTTP(w, r)
 			} else {
-				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				r.Error(w, router.NewHTTPError(http.StatusTooManyRequests, "too_many_requests", "Too many requests"))
 			}
 		})
 	}
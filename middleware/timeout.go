@@ -1,31 +1,131 @@
 package middleware
 
 import (
+	"bytes"
 	"context"
 	"github.com/jonecoboy/nina/router"
 	"net/http"
+	"sync"
 	"time"
 )
 
+// TimeoutMiddleware answers 504 when the handler runs longer than timeout.
+// Like http.TimeoutHandler the response is buffered until the handler
+// returns, writes made after the timeout fail with http.ErrHandlerTimeout.
 func TimeoutMiddleware(timeout time.Duration) router.Middleware {
 	return func(next router.Handler) router.Handler {
 		return router.Handler(func(w http.ResponseWriter, r *router.NinaRequest) {
 			ctx, cancel := context.WithTimeout(r.Context(), timeout)
 			defer cancel()
 
-			r.Request = r.Request.WithContext(ctx)
+			// The handler gets its own copy of the request, r keeps its
+			// context for the outer middlewares and the timeout response
+			inner := r.Derive(ctx)
+			tw := &timeoutWriter{ctx: ctx, header: make(http.Header)}
 
 			done := make(chan struct{})
+			panicked := make(chan interface{}, 1)
 			go func() {
-				next.ServeHTTP(w, r)
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+					}
+				}()
+				next.ServeHTTP(tw, inner)
 				close(done)
 			}()
 
 			select {
-			case <-ctx.Done():
-				http.Error(w, "Request timed out", http.StatusGatewayTimeout)
+			case p := <-panicked:
+				panic(p)
 			case <-done:
+				if tw.flushTo(w) {
+					return
+				}
+			case <-ctx.Done():
+				if !tw.timeout() {
+					// The parent context was cancelled, the client is gone
+					return
+				}
 			}
+			r.Error(w, router.NewHTTPError(http.StatusGatewayTimeout, "timeout", "Request timed out").Wrap(ctx.Err()))
 		})
 	}
 }
+
+// timeoutWriter buffers the response of a handler run by TimeoutMiddleware
+type timeoutWriter struct {
+	ctx         context.Context
+	mu          sync.Mutex
+	header      http.Header
+	buf         bytes.Buffer
+	status      int
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+// timeout marks the response as timed out once the deadline passed, the
+// handler and the middleware may both see it first
+func (tw *timeoutWriter) timeout() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.timeoutLocked()
+}
+
+func (tw *timeoutWriter) timeoutLocked() bool {
+	if !tw.timedOut && tw.ctx.Err() == context.DeadlineExceeded {
+		tw.timedOut = true
+	}
+	return tw.timedOut
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timeoutLocked() {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timeoutLocked() || tw.wroteHeader {
+		return
+	}
+	tw.writeHeaderLocked(status)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(status int) {
+	tw.wroteHeader = true
+	tw.status = status
+}
+
+// flushTo sends the buffered response and reports true when the handler
+// returned before the deadline
+func (tw *timeoutWriter) flushTo(w http.ResponseWriter) bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return false
+	}
+	dst := w.Header()
+	for key, values := range tw.header {
+		dst[key] = values
+	}
+	if tw.wroteHeader {
+		w.WriteHeader(tw.status)
+	}
+	if tw.buf.Len() > 0 {
+		w.Write(tw.buf.Bytes())
+	}
+	return true
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/jonecoboy/nina/router"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -54,3 +55,91 @@ func TestTimeoutMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestTimeoutMiddlewareLateWrites(t *testing.T) {
+	lateErr := make(chan error, 1)
+	nr := router.NewRouter()
+	nr.GET("/late", func(w http.ResponseWriter, r *router.NinaRequest) {
+		<-r.Context().Done()
+		w.Header().Set("X-Late", "1")
+		_, err := w.Write([]byte("late"))
+		lateErr <- err
+	}, []router.Middleware{TimeoutMiddleware(10 * time.Millisecond)})
+	nr.GET("/created", func(w http.ResponseWriter, r *router.NinaRequest) {
+		w.Header().Set("Location", "/created/1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	}, []router.Middleware{TimeoutMiddleware(time.Second)})
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+		wantHeader string
+	}{
+		{"Late write dropped", "/late", http.StatusGatewayTimeout, "Request timed out", ""},
+		{"Buffered response sent", "/created", http.StatusCreated, "created", "/created/1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			nr.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %v, want %v", rr.Code, tt.wantStatus)
+			}
+			if !strings.Contains(rr.Body.String(), tt.wantBody) {
+				t.Errorf("got body %v, want %v", rr.Body.String(), tt.wantBody)
+			}
+			if got := rr.Header().Get("Location"); got != tt.wantHeader {
+				t.Errorf("got Location %v, want %v", got, tt.wantHeader)
+			}
+		})
+	}
+
+	if err := <-lateErr; err != http.ErrHandlerTimeout {
+		t.Errorf("got late write error %v, want %v", err, http.ErrHandlerTimeout)
+	}
+}
+
+func TestTimeoutMiddlewareKeepsOuterContext(t *testing.T) {
+	outerErr := make(chan error, 1)
+	outer := func(next router.Handler) router.Handler {
+		return func(w http.ResponseWriter, r *router.NinaRequest) {
+			next(w, r)
+			outerErr <- r.Context().Err()
+		}
+	}
+
+	nr := router.NewRouter()
+	nr.Use(outer)
+	nr.GET("/timeout", func(w http.ResponseWriter, r *router.NinaRequest) {
+		<-r.Context().Done()
+	}, []router.Middleware{TimeoutMiddleware(10 * time.Millisecond)})
+	nr.GET("/fast", func(w http.ResponseWriter, r *router.NinaRequest) {}, []router.Middleware{TimeoutMiddleware(time.Second)})
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{"After the timeout fired", "/timeout", http.StatusGatewayTimeout},
+		{"After the handler returned", "/fast", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			nr.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %v, want %v", rr.Code, tt.wantStatus)
+			}
+			if err := <-outerErr; err != nil {
+				t.Errorf("got outer context error %v, want a live context", err)
+			}
+		})
+	}
+}
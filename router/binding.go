@@ -0,0 +1,377 @@
+package router
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Struct tags read by the binders, one per request source
+const (
+	tagURI    = "uri"
+	tagQuery  = "query"
+	tagHeader = "header"
+	tagForm   = "form"
+)
+
+// default layout for time.Time fields, override it per field with time_format:"..."
+const defaultTimeFormat = time.RFC3339
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// BindingError describes a value that could not be converted into its field.
+type BindingError struct {
+	Source string // uri, query, header, form, json, xml or the name of a registered decoder
+	Field  string
+	Value  string
+	Err    error
+}
+
+func (e *BindingError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("bind %s: %v", e.Source, e.Err)
+	}
+	return fmt.Sprintf("bind %s field %q with value %q: %v", e.Source, e.Field, e.Value, e.Err)
+}
+
+func (e *BindingError) Unwrap() error {
+	return e.Err
+}
+
+// Bind fills dst from the URI params, the query string, the headers and finally
+// the body, picking the body binder from the Content-Type header the same way
+// the router picks the body parser. dst must be a pointer to a struct. The
+// body is skipped when the route streams it.
+func (nr *NinaRequest) Bind(dst interface{}) error {
+	if err := nr.BindURI(dst); err != nil {
+		return err
+	}
+	if err := nr.BindQuery(dst); err != nil {
+		return err
+	}
+	if err := nr.BindHeader(dst); err != nil {
+		return err
+	}
+	kind := nr.router.bodyKind(nr.Header.Get("Content-Type"))
+	if kind == bodyMultipart {
+		return nr.BindForm(dst)
+	}
+	if err := nr.loadBody(); err != nil {
+		if errors.Is(err, ErrBodyStreamed) {
+			return nil
+		}
+		return err
+	}
+	if len(nr.rawBody) == 0 {
+		return nil
+	}
+
+	if kind == bodyDecoded {
+		return nr.BindBody(dst)
+	}
+	return nr.BindForm(dst)
+}
+
+// BindBody decodes the body into dst with the Decoder registered for the
+// Content-Type header.
+func (nr *NinaRequest) BindBody(dst interface{}) error {
+	if err := checkBindTarget(dst); err != nil {
+		return err
+	}
+	contentType := nr.Header.Get("Content-Type")
+	decoder, mediaType := nr.router.findDecoder(contentType)
+	if decoder == nil {
+		return &BindingError{Source: "body", Err: fmt.Errorf("no decoder registered for %q", contentType)}
+	}
+	if err := nr.loadBody(); err != nil {
+		return err
+	}
+	name := decoderName(mediaType)
+	if len(nr.rawBody) == 0 {
+		return &BindingError{Source: name, Err: errors.New("body is empty")}
+	}
+	if err := decoder.Decode(nr.rawBody, dst); err != nil {
+		return &BindingError{Source: name, Err: err}
+	}
+	return nil
+}
+
+// BindJSON decodes the JSON body into dst using the json struct tags.
+func (nr *NinaRequest) BindJSON(dst interface{}) error {
+	if err := checkBindTarget(dst); err != nil {
+		return err
+	}
+	if err := nr.loadBody(); err != nil {
+		return err
+	}
+	if len(nr.rawBody) == 0 {
+		return &BindingError{Source: "json", Err: errors.New("body is empty")}
+	}
+	if err := json.Unmarshal(nr.rawBody, dst); err != nil {
+		return &BindingError{Source: "json", Err: err}
+	}
+	return nil
+}
+
+// BindXML decodes the XML body into dst using the xml struct tags.
+func (nr *NinaRequest) BindXML(dst interface{}) error {
+	if err := checkBindTarget(dst); err != nil {
+		return err
+	}
+	if err := nr.loadBody(); err != nil {
+		return err
+	}
+	if len(nr.rawBody) == 0 {
+		return &BindingError{Source: "xml", Err: errors.New("body is empty")}
+	}
+	if err := xml.Unmarshal(nr.rawBody, dst); err != nil {
+		return &BindingError{Source: "xml", Err: err}
+	}
+	return nil
+}
+
+// BindQuery fills the fields tagged with query:"name" from the query string.
+func (nr *NinaRequest) BindQuery(dst interface{}) error {
+	return bindValues(dst, tagQuery, normalizeValues(nr.URL.Query()))
+}
+
+// BindURI fills the fields tagged with uri:"name" from the path params.
+func (nr *NinaRequest) BindURI(dst interface{}) error {
+	values := make(map[string][]string)
+	if nr.Params != nil {
+		for key, value := range nr.Params.UriParams {
+			values[key] = []string{value}
+		}
+	}
+	return bindValues(dst, tagURI, values)
+}
+
+// BindHeader fills the fields tagged with header:"Name" from the request headers.
+func (nr *NinaRequest) BindHeader(dst interface{}) error {
+	values := make(map[string][]string, len(nr.Header))
+	for key, value := range nr.Header {
+		values[key] = value
+	}
+	return bindValuesWith(dst, tagHeader, values, textproto.CanonicalMIMEHeaderKey)
+}
+
+// BindForm fills the fields tagged with form:"name" from the url-encoded or
+// multipart form, or from the key=value pairs of a raw body.
+func (nr *NinaRequest) BindForm(dst interface{}) error {
+	values := make(map[string][]string)
+	switch kind := nr.router.bodyKind(nr.Header.Get("Content-Type")); {
+	case kind == bodyForm:
+		if err := nr.loadBody(); err != nil {
+			return err
+		}
+		if err := nr.Request.ParseForm(); err != nil {
+			return &BindingError{Source: tagForm, Err: err}
+		}
+		values = normalizeValues(nr.Request.PostForm)
+	case kind == bodyMultipart:
+		if err := nr.ParseMultipart(nr.multipartOptions()); err != nil {
+			return err
+		}
+		values = normalizeValues(nr.MultipartForm.Value)
+	default:
+		if err := nr.loadBody(); err != nil {
+			return err
+		}
+		if body, ok := nr.body.(map[string]interface{}); ok {
+			for key, value := range body {
+				if s, ok := value.(string); ok {
+					values[key] = []string{s}
+				}
+			}
+		}
+	}
+	return bindValues(dst, tagForm, values)
+}
+
+func checkBindTarget(dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("bind target must be a non-nil pointer")
+	}
+	return nil
+}
+
+func bindValues(dst interface{}, tag string, values map[string][]string) error {
+	return bindValuesWith(dst, tag, values, nil)
+}
+
+// bindValuesWith walks the struct pointed by dst and sets every field tagged
+// with tag from values. normalize, when set, is applied to the tag name before
+// the lookup (headers are canonicalized for instance).
+func bindValuesWith(dst interface{}, tag string, values map[string][]string, normalize func(string) string) error {
+	if err := checkBindTarget(dst); err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(dst).Elem()
+	if rv.Kind() != reflect.Struct {
+		return errors.New("bind target must point to a struct")
+	}
+	return bindStruct(rv, tag, "", values, normalize)
+}
+
+func bindStruct(rv reflect.Value, tag, prefix string, values map[string][]string, normalize func(string) string) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, hasTag := field.Tag.Lookup(tag)
+		if name == "-" {
+			continue
+		}
+		if idx := strings.Index(name, ","); idx != -1 {
+			name = name[:idx]
+		}
+
+		// Nested structs: untagged ones share the current level, tagged ones
+		// are looked up as "parent.child"
+		if isNestedStruct(field.Type) {
+			nestedPrefix := prefix
+			if hasTag && name != "" {
+				nestedPrefix = prefix + name + "."
+			}
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					fv.Set(reflect.New(field.Type.Elem()))
+				}
+				fv = fv.Elem()
+			}
+			if err := bindStruct(fv, tag, nestedPrefix, values, normalize); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !hasTag || name == "" {
+			continue
+		}
+		key := prefix + name
+		if normalize != nil {
+			key = normalize(key)
+		}
+		raw, ok := values[key]
+		if !ok || len(raw) == 0 {
+			continue
+		}
+		if err := setField(fv, field, raw); err != nil {
+			return &BindingError{Source: tag, Field: key, Value: strings.Join(raw, ","), Err: err}
+		}
+	}
+	return nil
+}
+
+// isNestedStruct reports whether t is a struct the binders should walk into
+// instead of a leaf value like time.Time or a TextUnmarshaler.
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return false
+	}
+	return !reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+func setField(fv reflect.Value, field reflect.StructField, raw []string) error {
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(fv.Type(), len(raw), len(raw))
+		for i, value := range raw {
+			if err := setValue(slice.Index(i), field, value); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	}
+	return setValue(fv, field, raw[0])
+}
+
+// setValue converts value into the type of fv
+func setValue(fv reflect.Value, field reflect.StructField, value string) error {
+	if fv.Kind() == reflect.Ptr {
+		ptr := reflect.New(fv.Type().Elem())
+		if err := setValue(ptr.Elem(), field, value); err != nil {
+			return err
+		}
+		fv.Set(ptr)
+		return nil
+	}
+
+	if fv.Type() == timeType {
+		layout := field.Tag.Get("time_format")
+		if layout == "" {
+			layout = defaultTimeFormat
+		}
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if fv.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+	if fv.CanAddr() && fv.Addr().Type().Implements(textUnmarshalerType) {
+		return fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	case reflect.Slice:
+		// []byte gets the raw value
+		fv.SetBytes([]byte(value))
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindAddress struct {
+	City string `json:"city" query:"city" form:"city"`
+}
+
+type bindUser struct {
+	ID        int           `uri:"id"`
+	Verbose   bool          `query:"verbose"`
+	Tags      []string      `query:"tag"`
+	Since     time.Time     `query:"since"`
+	Day       time.Time     `query:"day" time_format:"2006-01-02"`
+	Timeout   time.Duration `query:"timeout"`
+	Limit     *uint8        `query:"limit"`
+	RequestID string        `header:"x-request-id"`
+	Name      string        `json:"name" xml:"name" form:"name"`
+	Age       int           `json:"age" xml:"age" form:"age"`
+	Address   bindAddress   `json:"address" query:"address"`
+}
+
+func TestBind(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		contentType string
+		body        string
+		check       func(t *testing.T, u bindUser)
+	}{
+		{
+			name:        "JSON body with uri, query and header",
+			url:         "/users/42?verbose=true&tag=a&tag=b&since=2024-01-02T03:04:05Z&day=2024-05-06&timeout=1m30s&limit=7&address.city=Lisbon",
+			contentType: "application/json",
+			body:        `{"name":"nina","age":3}`,
+			check: func(t *testing.T, u bindUser) {
+				if u.ID != 42 || !u.Verbose || u.RequestID != "req-1" {
+					t.Errorf("got id=%v verbose=%v request id=%q", u.ID, u.Verbose, u.RequestID)
+				}
+				if strings.Join(u.Tags, ",") != "a,b" {
+					t.Errorf("got tags %v, want [a b]", u.Tags)
+				}
+				if !u.Since.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+					t.Errorf("got since %v", u.Since)
+				}
+				if u.Day.Format("2006-01-02") != "2024-05-06" {
+					t.Errorf("got day %v", u.Day)
+				}
+				if u.Timeout != 90*time.Second {
+					t.Errorf("got timeout %v", u.Timeout)
+				}
+				if u.Limit == nil || *u.Limit != 7 {
+					t.Errorf("got limit %v", u.Limit)
+				}
+				if u.Address.City != "Lisbon" {
+					t.Errorf("got city %q, want Lisbon", u.Address.City)
+				}
+				if u.Name != "nina" || u.Age != 3 {
+					t.Errorf("got name=%q age=%v", u.Name, u.Age)
+				}
+			},
+		},
+		{
+			name:        "XML body",
+			url:         "/users/1",
+			contentType: "application/xml",
+			body:        `<user><name>nina</name><age>5</age></user>`,
+			check: func(t *testing.T, u bindUser) {
+				if u.Name != "nina" || u.Age != 5 {
+					t.Errorf("got name=%q age=%v", u.Name, u.Age)
+				}
+			},
+		},
+		{
+			name:        "Form body",
+			url:         "/users/1",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "name=nina&age=9",
+			check: func(t *testing.T, u bindUser) {
+				if u.Name != "nina" || u.Age != 9 {
+					t.Errorf("got name=%q age=%v", u.Name, u.Age)
+				}
+			},
+		},
+		{
+			name: "Raw key=value body",
+			url:  "/users/1",
+			body: "name=nina,age=11",
+			check: func(t *testing.T, u bindUser) {
+				if u.Name != "nina" || u.Age != 11 {
+					t.Errorf("got name=%q age=%v", u.Name, u.Age)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nr := NewRouter()
+			var got bindUser
+			var bindErr error
+			nr.POST("/users/{id}", func(w http.ResponseWriter, r *NinaRequest) {
+				bindErr = r.Bind(&got)
+			}, nil)
+
+			req := httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader(tt.body))
+			req.Header.Set("X-Request-ID", "req-1")
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			nr.ServeHTTP(httptest.NewRecorder(), req)
+
+			if bindErr != nil {
+				t.Fatalf("unexpected bind error: %v", bindErr)
+			}
+			tt.check(t, got)
+		})
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	nr := NewRouter()
+	nr.GET("/users/{id}", func(w http.ResponseWriter, r *NinaRequest) {
+		var u bindUser
+		err := r.Bind(&u)
+		var bindErr *BindingError
+		if errors.As(err, &bindErr) {
+			fmt.Fprintf(w, "%s:%s", bindErr.Source, bindErr.Field)
+			return
+		}
+		fmt.Fprint(w, err)
+	}, nil)
+
+	tests := []struct {
+		url      string
+		wantBody string
+	}{
+		{"/users/abc", "uri:id"},
+		{"/users/1?verbose=maybe", "query:verbose"},
+		{"/users/1?limit=300", "query:limit"},
+		{"/users/1", "<nil>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			nr.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if rr.Body.String() != tt.wantBody {
+				t.Errorf("got body %v, want %v", rr.Body.String(), tt.wantBody)
+			}
+		})
+	}
+
+	req := &NinaRequest{Request: httptest.NewRequest(http.MethodGet, "/", nil)}
+	var notPointer bindUser
+	if err := req.BindQuery(notPointer); err == nil {
+		t.Error("expected an error when binding into a non-pointer")
+	}
+}
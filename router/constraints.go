@@ -0,0 +1,248 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// paramTypes are the named constraints usable as {name:type}, anything else
+// after the colon is a regular expression the whole segment must match.
+var paramTypes = map[string]func(string) bool{
+	"int": func(s string) bool {
+		_, err := strconv.ParseInt(s, 10, 64)
+		return err == nil
+	},
+	"uint": func(s string) bool {
+		_, err := strconv.ParseUint(s, 10, 64)
+		return err == nil
+	},
+	"float": func(s string) bool {
+		_, err := strconv.ParseFloat(s, 64)
+		return err == nil
+	},
+	"bool": func(s string) bool {
+		_, err := strconv.ParseBool(s)
+		return err == nil
+	},
+	// Same definitions as the validate rules
+	"uuid":     uuidRegex.MatchString,
+	"alpha":    alphaRegex.MatchString,
+	"alphanum": alphanumRegex.MatchString,
+}
+
+// routeParam is a {name} segment of a pattern, match is nil without constraint
+type routeParam struct {
+	name       string
+	wildcard   bool
+	constraint string // type name or regular expression, for the OpenAPI document
+	match      func(string) bool
+}
+
+// routePattern is the descriptor of a route pattern, built once when the
+// route is registered and shared by all its requests
+type routePattern struct {
+	std    string // pattern without the constraints, given to http.ServeMux
+	shape  string // pattern without the param names, equal for conflicting routes
+	params []routeParam
+	names  []string // param names, in order
+}
+
+// routeEntry holds every route sharing a shape, tried in order until the
+// constraints of one match
+type routeEntry struct {
+	names      []string // param names of the pattern registered in http.ServeMux
+	candidates []routeCandidate
+	// the only candidate has no constraint, nothing to check per request
+	direct http.Handler
+}
+
+type routeCandidate struct {
+	params  []routeParam
+	handler http.Handler
+}
+
+func (c routeCandidate) constrained() bool {
+	for _, param := range c.params {
+		if param.match != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRoutePattern splits pattern, {id:int} and {slug:[a-z-]+} segments are
+// registered as {id} and {slug} with their constraint kept aside.
+func parseRoutePattern(pattern string) routePattern {
+	var rp routePattern
+	var std, shape strings.Builder
+	eachPatternParam(pattern, func(literal, inner string) {
+		std.WriteString(literal)
+		shape.WriteString(literal)
+		if inner == "" {
+			return
+		}
+		if inner == "$" {
+			std.WriteString("{$}")
+			shape.WriteString("{$}")
+			return
+		}
+
+		name, constraint, _ := strings.Cut(inner, ":")
+		param := routeParam{name: name}
+		if strings.HasSuffix(name, "...") {
+			param.name, param.wildcard = strings.TrimSuffix(name, "..."), true
+		} else if strings.HasSuffix(constraint, "...") {
+			constraint, param.wildcard = strings.TrimSuffix(constraint, "..."), true
+		}
+		if constraint != "" {
+			param.constraint = constraint
+			param.match = paramConstraint(pattern, constraint)
+		}
+		rp.params = append(rp.params, param)
+		rp.names = append(rp.names, param.name)
+
+		if param.wildcard {
+			std.WriteString("{" + param.name + "...}")
+			shape.WriteString("{...}")
+		} else {
+			std.WriteString("{" + param.name + "}")
+			shape.WriteString("{}")
+		}
+	})
+	rp.std, rp.shape = std.String(), shape.String()
+	return rp
+}
+
+func paramConstraint(pattern, constraint string) func(string) bool {
+	if match, ok := paramTypes[constraint]; ok {
+		return match
+	}
+	re, err := regexp.Compile("^(?:" + constraint + ")$")
+	if err != nil {
+		panic(fmt.Sprintf("router: invalid constraint in pattern %q: %v", pattern, err))
+	}
+	return re.MatchString
+}
+
+// eachPatternParam calls fn with the literal text before every {...} segment
+// and the segment content, braces inside a regex constraint are balanced.
+// The text after the last segment is given with an empty inner.
+func eachPatternParam(pattern string, fn func(literal, inner string)) {
+	start := 0
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '{' {
+			continue
+		}
+		depth, end := 0, -1
+		for j := i; j < len(pattern) && end == -1; j++ {
+			switch pattern[j] {
+			case '\\':
+				j++
+			case '{':
+				depth++
+			case '}':
+				if depth--; depth == 0 {
+					end = j
+				}
+			}
+		}
+		if end == -1 {
+			panic(fmt.Sprintf("router: unbalanced braces in pattern %q", pattern))
+		}
+		fn(pattern[start:i], pattern[i+1:end])
+		start, i = end+1, end
+	}
+	fn(pattern[start:], "")
+}
+
+// handleRoute registers handler for method ("" for any) on the pattern,
+// routes with the same shape share one http.ServeMux pattern and are told
+// apart by their constraints.
+func (mux *ServeMux) handleRoute(method string, rp routePattern, handler http.Handler) {
+	key := rp.shape
+	if method != "" {
+		key = method + " " + key
+	}
+	if mux.routes == nil {
+		mux.routes = make(map[string]*routeEntry)
+	}
+
+	entry, found := mux.routes[key]
+	if !found {
+		entry = &routeEntry{names: rp.names}
+		mux.routes[key] = entry
+
+		std := rp.std
+		if method != "" {
+			std = method + " " + std
+		}
+		mux.ServeMux.Handle(std, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mux.serveEntry(entry, w, r)
+		}))
+	}
+
+	candidate := routeCandidate{params: rp.params, handler: handler}
+	if !candidate.constrained() {
+		for _, existing := range entry.candidates {
+			if !existing.constrained() {
+				panic(fmt.Sprintf("router: pattern %q conflicts with a route registered before", rp.std))
+			}
+		}
+		entry.candidates = append(entry.candidates, candidate)
+		if len(entry.candidates) == 1 {
+			entry.direct = handler
+		}
+		return
+	}
+	// Constrained routes are tried before the unconstrained one
+	idx := len(entry.candidates)
+	for i, existing := range entry.candidates {
+		if !existing.constrained() {
+			idx = i
+			break
+		}
+	}
+	entry.candidates = append(entry.candidates[:idx], append([]routeCandidate{candidate}, entry.candidates[idx:]...)...)
+	entry.direct = nil
+}
+
+// serveEntry runs the first route of entry whose constraints match, the
+// request is not found when there is none
+func (mux *ServeMux) serveEntry(entry *routeEntry, w http.ResponseWriter, r *http.Request) {
+	if entry.direct != nil {
+		entry.direct.ServeHTTP(w, r)
+		return
+	}
+
+	var buf [8]string
+	values := buf[:0]
+	for _, name := range entry.names {
+		values = append(values, r.PathValue(name))
+	}
+
+	for _, candidate := range entry.candidates {
+		if !candidate.matches(values) {
+			continue
+		}
+		for i, param := range candidate.params {
+			if param.name != entry.names[i] {
+				r.SetPathValue(param.name, values[i])
+			}
+		}
+		candidate.handler.ServeHTTP(w, r)
+		return
+	}
+	mux.serveFallback(w, r, mux.notFound)
+}
+
+func (c routeCandidate) matches(values []string) bool {
+	for i, param := range c.params {
+		if param.match != nil && !param.match(values[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouteConstraints(t *testing.T) {
+	nr := NewRouter()
+
+	nr.GET("/users/{id:int}", func(w http.ResponseWriter, r *NinaRequest) {
+		id, err := r.ParamInt("id")
+		fmt.Fprintf(w, "id %d %v", id, err)
+	}, nil)
+	nr.GET("/users/{slug:[a-z-]+}", func(w http.ResponseWriter, r *NinaRequest) {
+		fmt.Fprintf(w, "slug %s", r.Param("slug"))
+	}, nil)
+	nr.GET("/orders/{code:[A-Z]{3}-[0-9]+}", func(w http.ResponseWriter, r *NinaRequest) {
+		fmt.Fprintf(w, "order %s", r.PathValue("code"))
+	}, nil)
+	nr.GET("/items/{ref:uuid}", func(w http.ResponseWriter, r *NinaRequest) {
+		fmt.Fprintf(w, "uuid %s", r.Param("ref"))
+	}, nil)
+	// Registered first, still tried after the constrained route of the same shape
+	nr.GET("/tags/{name}", func(w http.ResponseWriter, r *NinaRequest) {
+		fmt.Fprintf(w, "tag %s", r.Param("name"))
+	}, nil)
+	nr.GET("/tags/{id:int}", func(w http.ResponseWriter, r *NinaRequest) {
+		id, _ := r.ParamInt64("id")
+		fmt.Fprintf(w, "tag id %d", id)
+	}, nil)
+	nr.GET("/files/{path...}", func(w http.ResponseWriter, r *NinaRequest) {
+		fmt.Fprintf(w, "file %s", r.Param("path"))
+	}, nil)
+	nr.GROUP("/v1", nil, nil).GET("/prices/{amount:float}", func(w http.ResponseWriter, r *NinaRequest) {
+		amount, _ := r.ParamFloat("amount")
+		fmt.Fprintf(w, "price %.2f", amount)
+	}, nil)
+
+	tests := []struct {
+		url        string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/users/42", http.StatusOK, "id 42 <nil>"},
+		{"/users/-7", http.StatusOK, "id -7 <nil>"},
+		{"/users/nina-rossi", http.StatusOK, "slug nina-rossi"},
+		{"/users/Nina", http.StatusNotFound, ""},
+		{"/users/99999999999999999999", http.StatusNotFound, ""},
+		{"/orders/ABC-12", http.StatusOK, "order ABC-12"},
+		{"/orders/AB-12", http.StatusNotFound, ""},
+		{"/items/0b9e5a4c-7c5a-4b8e-9f0e-3c2d1a0b9e5a", http.StatusOK, "uuid 0b9e5a4c-7c5a-4b8e-9f0e-3c2d1a0b9e5a"},
+		{"/items/42", http.StatusNotFound, ""},
+		{"/tags/7", http.StatusOK, "tag id 7"},
+		{"/tags/go", http.StatusOK, "tag go"},
+		{"/files/docs/readme.md", http.StatusOK, "file docs/readme.md"},
+		{"/v1/prices/9.5", http.StatusOK, "price 9.50"},
+		{"/v1/prices/free", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			nr.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %v, want %v", rr.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusNotFound && rr.Body.String() != notFoundBody(tt.url) {
+				t.Errorf("got body %v, want %v", rr.Body.String(), notFoundBody(tt.url))
+			}
+			if tt.wantBody != "" && rr.Body.String() != tt.wantBody {
+				t.Errorf("got body %v, want %v", rr.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestParamAccessorErrors(t *testing.T) {
+	nr := NewRouter()
+	nr.GET("/users/{id}", E(func(w http.ResponseWriter, r *NinaRequest) error {
+		if _, err := r.ParamInt("missing"); err == nil {
+			t.Error("expected an error for a missing param")
+		}
+		_, err := r.ParamInt("id")
+		return err
+	}), nil)
+
+	rr := httptest.NewRecorder()
+	nr.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users/nina", nil))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("got status %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestRoutePatternPanics(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+	}{
+		{"Invalid regex", []string{"/users/{id:[0-9}"}},
+		{"Unbalanced braces", []string{"/users/{id"}},
+		{"Duplicate route", []string{"/users/{id}", "/users/{name}"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			nr := NewRouter()
+			for _, pattern := range tt.patterns {
+				nr.GET(pattern, func(w http.ResponseWriter, r *NinaRequest) {}, nil)
+			}
+		})
+	}
+}
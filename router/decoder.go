@@ -0,0 +1,93 @@
+package router
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Decoder parses request bodies of a media type. v is a
+// *map[string]interface{} when the body is parsed for GetBody, or the pointer
+// given to Bind.
+type Decoder interface {
+	Decode(data []byte, v interface{}) error
+}
+
+// DecoderFunc adapts a function like json.Unmarshal to a Decoder.
+type DecoderFunc func(data []byte, v interface{}) error
+
+func (f DecoderFunc) Decode(data []byte, v interface{}) error {
+	return f(data, v)
+}
+
+// defaultDecoders are the decoders of a router until RegisterDecoder is
+// called on it, they are never modified
+var defaultDecoders = map[string]Decoder{
+	"application/json": DecoderFunc(json.Unmarshal),
+	"application/xml":  XMLDecoder(XMLOptions{}),
+	"text/xml":         XMLDecoder(XMLOptions{}),
+	KVContentType:      KVCodec{},
+}
+
+// RegisterDecoder makes d parse the bodies sent to the router as mediaType,
+// replacing the decoder registered for it, a nil d removes it. Media type
+// parameters are ignored, and a decoder registered for application/<format>
+// also handles the +<format> suffix, like application/json does for
+// application/vnd.api+json. Form and multipart bodies are always handled by
+// the router.
+//
+// Every router starts with the JSON, XML and KV decoders, mounted routers
+// keep their own. Like Use, it must be called before the router serves
+// requests.
+func (mux *ServeMux) RegisterDecoder(mediaType string, d Decoder) {
+	mediaType = declaredType(mediaType)
+	if mux.decoders == nil {
+		mux.decoders = make(map[string]Decoder, len(defaultDecoders)+1)
+		for name, decoder := range defaultDecoders {
+			mux.decoders[name] = decoder
+		}
+	}
+	if d == nil {
+		delete(mux.decoders, mediaType)
+		return
+	}
+	mux.decoders[mediaType] = d
+}
+
+// LookupDecoder returns the decoder of the router for a Content-Type header
+// value, nil when none is registered.
+func (mux *ServeMux) LookupDecoder(contentType string) Decoder {
+	d, _ := mux.findDecoder(contentType)
+	return d
+}
+
+// findDecoder returns the decoder of contentType along with the media type it
+// was registered for. A nil router uses the default decoders.
+func (mux *ServeMux) findDecoder(contentType string) (Decoder, string) {
+	mediaType := declaredType(contentType)
+	if mediaType == "" {
+		return nil, ""
+	}
+
+	decoders := defaultDecoders
+	if mux != nil && mux.decoders != nil {
+		decoders = mux.decoders
+	}
+	if d, ok := decoders[mediaType]; ok {
+		return d, mediaType
+	}
+	// Structured syntax suffix (RFC 6839), application/vnd.api+json is JSON
+	if idx := strings.LastIndex(mediaType, "+"); idx != -1 {
+		suffixType := "application/" + mediaType[idx+1:]
+		if d, ok := decoders[suffixType]; ok {
+			return d, suffixType
+		}
+	}
+	return nil, ""
+}
+
+// decoderName gives a short name for the errors of a decoder, "json" for
+// application/json or "yaml" for application/x-yaml
+func decoderName(mediaType string) string {
+	name := mediaType[strings.Index(mediaType, "/")+1:]
+	return strings.TrimPrefix(name, "x-")
+}
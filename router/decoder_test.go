@@ -0,0 +1,141 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// yamlLines decodes "key: value" lines, enough to test a third party decoder
+func yamlLines(data []byte, v interface{}) error {
+	m := make(map[string]interface{})
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return errors.New("missing ':'")
+		}
+		m[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	if dst, ok := v.(*map[string]interface{}); ok {
+		*dst = m
+		return nil
+	}
+	b, _ := json.Marshal(m)
+	return json.Unmarshal(b, v)
+}
+
+func TestDecoderRegistry(t *testing.T) {
+	nr := NewRouter()
+	nr.RegisterDecoder("application/yaml", DecoderFunc(yamlLines))
+	nr.POST("/users", E(func(w http.ResponseWriter, r *NinaRequest) error {
+		body, err := r.GetBody()
+		if err != nil {
+			return err
+		}
+		var user struct {
+			Name string `json:"name" xml:"name"`
+		}
+		if err := r.Bind(&user); err != nil {
+			return err
+		}
+		return r.Response(w).Text(http.StatusOK, fmt.Sprintf("%v|%s", body["name"], user.Name))
+	}), nil)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantBody    string
+	}{
+		{"JSON", "application/json", `{"name":"nina"}`, http.StatusOK, "nina|nina"},
+		{"JSON with charset", "application/json; charset=utf-8", `{"name":"nina"}`, http.StatusOK, "nina|nina"},
+		{"JSON upper case", "Application/JSON", `{"name":"nina"}`, http.StatusOK, "nina|nina"},
+		{"JSON suffix", "application/vnd.api+json", `{"name":"nina"}`, http.StatusOK, "nina|nina"},
+		// The XML map is keyed by the root element, so only Bind finds the name
+		{"XML suffix", "application/atom+xml; charset=utf-8", `<user><name>nina</name></user>`, http.StatusOK, "<nil>|nina"},
+		{"Registered decoder", "application/yaml", "name: nina", http.StatusOK, "nina|nina"},
+		{"Registered decoder suffix", "application/vnd.nina+yaml", "name: nina", http.StatusOK, "nina|nina"},
+		{"Registered decoder failure", "application/yaml", "nina", http.StatusBadRequest, ""},
+		{"Invalid JSON suffix", "application/vnd.api+json", `{`, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rr := httptest.NewRecorder()
+
+			nr.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %v, want %v: %v", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantBody != "" && rr.Body.String() != tt.wantBody {
+				t.Errorf("got body %v, want %v", rr.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestDecoderErrorCodes(t *testing.T) {
+	mux := NewRouter()
+	mux.RegisterDecoder("application/x-yaml", DecoderFunc(yamlLines))
+
+	tests := []struct {
+		contentType string
+		wantCode    string
+	}{
+		{"application/json", "invalid_json"},
+		{"application/problem+json", "invalid_json"},
+		{"text/xml", "invalid_xml"},
+		{"application/x-yaml", "invalid_yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("<{"))
+			req.Header.Set("Content-Type", tt.contentType)
+			_, _, err := mux.parseBody(req)
+			if got := AsHTTPError(err); got.Status != http.StatusBadRequest || got.Code != tt.wantCode {
+				t.Errorf("got %v %v, want 400 %v", got.Status, got.Code, tt.wantCode)
+			}
+		})
+	}
+
+	if mux.LookupDecoder("text/plain") != nil {
+		t.Error("text/plain should not have a decoder")
+	}
+}
+
+func TestDecodersPerRouter(t *testing.T) {
+	custom := NewRouter()
+	custom.RegisterDecoder("application/yaml", DecoderFunc(yamlLines))
+	custom.RegisterDecoder("text/xml", nil)
+	plain := NewRouter()
+
+	tests := []struct {
+		name        string
+		mux         *ServeMux
+		contentType string
+		wantDecoder bool
+	}{
+		{"Registered decoder", custom, "application/yaml", true},
+		{"Defaults kept", custom, "application/json", true},
+		{"Default removed", custom, "text/xml", false},
+		{"Other router unaffected", plain, "application/yaml", false},
+		{"Other router keeps removed default", plain, "text/xml", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.mux.LookupDecoder(tt.contentType) != nil; got != tt.wantDecoder {
+				t.Errorf("got decoder %v, want %v", got, tt.wantDecoder)
+			}
+		})
+	}
+}
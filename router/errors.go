@@ -0,0 +1,177 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// HTTPError is an error carrying everything needed to answer the client.
+type HTTPError struct {
+	Status  int         `json:"status"`
+	Code    string      `json:"code,omitempty"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+	// Type is the problem type URI used in problem details, about:blank when empty
+	Type string `json:"-"`
+	// Extensions are extra members added to the problem details document
+	Extensions map[string]interface{} `json:"-"`
+	Err        error                  `json:"-"` // internal cause, never sent to the client
+}
+
+// NewHTTPError creates an HTTPError, message defaults to the status text.
+func NewHTTPError(status int, code, message string) *HTTPError {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return &HTTPError{Status: status, Code: code, Message: message}
+}
+
+// WithDetails attaches extra data to the error sent to the client.
+func (e *HTTPError) WithDetails(details interface{}) *HTTPError {
+	e.Details = details
+	return e
+}
+
+// WithType sets the problem type URI of the error.
+func (e *HTTPError) WithType(typeURI string) *HTTPError {
+	e.Type = typeURI
+	return e
+}
+
+// WithExtension adds an extension member to the problem details of the error.
+func (e *HTTPError) WithExtension(key string, value interface{}) *HTTPError {
+	if e.Extensions == nil {
+		e.Extensions = make(map[string]interface{})
+	}
+	e.Extensions[key] = value
+	return e
+}
+
+// Wrap records err as the internal cause of e.
+func (e *HTTPError) Wrap(err error) *HTTPError {
+	e.Err = err
+	return e
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// HandlerE is a Handler that returns an error instead of writing it. Returned
+// errors are rendered by the router ErrorHandler.
+type HandlerE func(w http.ResponseWriter, r *NinaRequest) error
+
+func (h HandlerE) ServeHTTP(w http.ResponseWriter, r *NinaRequest) {
+	if err := h(w, r); err != nil {
+		r.Error(w, err)
+	}
+}
+
+// E adapts a HandlerE so it can be registered like any other Handler. Routes
+// reports such handlers as router.HandlerE.ServeHTTP, register them with
+// HandleE to keep the name of h.
+func E(h HandlerE) Handler {
+	return h.ServeHTTP
+}
+
+// ErrorHandler renders err to the client.
+type ErrorHandler func(w http.ResponseWriter, r *NinaRequest, err error)
+
+// Error renders err with the ErrorHandler of the router that built the
+// request, or DefaultErrorHandler when there is none.
+func (nr *NinaRequest) Error(w http.ResponseWriter, err error) {
+	if err == nil {
+		return
+	}
+	if res, ok := w.(*NinaResponse); ok && res.Written() {
+		// Too late to change the response, at least keep a trace of it
+		log.Printf("error after response was written: %v", err)
+		return
+	}
+
+	handler := DefaultErrorHandler
+	if nr.router != nil && nr.router.ErrorHandler != nil {
+		handler = nr.router.ErrorHandler
+	}
+	handler(w, nr, err)
+}
+
+// AsHTTPError converts any error into an HTTPError. Errors known by the router
+// keep their meaning, anything else becomes a 500 that does not leak the cause.
+func AsHTTPError(err error) *HTTPError {
+	var httpErr *HTTPError
+	var validationErrors ValidationErrors
+	var bindingErr *BindingError
+	var encodeErr *EncodeError
+
+	switch {
+	case errors.As(err, &httpErr):
+		return httpErr
+	case errors.As(err, &validationErrors):
+		return NewHTTPError(http.StatusUnprocessableEntity, "validation_failed", "Invalid request parameters").
+			WithDetails(validationErrors).Wrap(err)
+	case errors.As(err, &bindingErr):
+		return NewHTTPError(http.StatusBadRequest, "binding_failed", "Invalid request parameters").
+			WithDetails(map[string]string{"source": bindingErr.Source, "field": bindingErr.Field}).Wrap(err)
+	case errors.Is(err, ErrNotAcceptable):
+		return NewHTTPError(http.StatusNotAcceptable, "not_acceptable", "").Wrap(err)
+	case errors.As(err, &encodeErr):
+		return NewHTTPError(http.StatusInternalServerError, "encode_failed", "").Wrap(err)
+	default:
+		return NewHTTPError(http.StatusInternalServerError, "internal_error", "").Wrap(err)
+	}
+}
+
+// DefaultErrorHandler is the ErrorHandler used by routers that do not set
+// one, it renders errors as RFC 9457 problem details.
+func DefaultErrorHandler(w http.ResponseWriter, r *NinaRequest, err error) {
+	ProblemErrorHandler(w, r, err)
+}
+
+// ProblemErrorHandler writes err as an application/problem+json document.
+func ProblemErrorHandler(w http.ResponseWriter, r *NinaRequest, err error) {
+	httpErr := logHTTPError(err)
+	WriteProblem(w, httpErr.Problem(r))
+}
+
+// JSONErrorHandler writes err as a plain JSON document with the status, code,
+// message and details of the matching HTTPError.
+func JSONErrorHandler(w http.ResponseWriter, r *NinaRequest, err error) {
+	httpErr := logHTTPError(err)
+
+	body, encodeErr := json.Marshal(httpErr)
+	if encodeErr != nil {
+		// Details could not be encoded, send the error without them
+		body, _ = json.Marshal(NewHTTPError(httpErr.Status, httpErr.Code, httpErr.Message))
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(httpErr.Status)
+	w.Write(append(body, '\n'))
+}
+
+// TextErrorHandler writes the error message as text/plain like http.Error,
+// for routers that do not want structured errors.
+func TextErrorHandler(w http.ResponseWriter, r *NinaRequest, err error) {
+	httpErr := logHTTPError(err)
+	http.Error(w, httpErr.Message, httpErr.Status)
+}
+
+// logHTTPError converts err and logs it when it is a server side failure
+func logHTTPError(err error) *HTTPError {
+	httpErr := AsHTTPError(err)
+	if httpErr.Status >= http.StatusInternalServerError {
+		log.Printf("%d %s: %v", httpErr.Status, httpErr.Code, err)
+	}
+	return httpErr
+}
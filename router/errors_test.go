@@ -0,0 +1,187 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlerEAndDefaultErrorHandler(t *testing.T) {
+	nr := NewRouter()
+
+	nr.GET("/teapot", E(func(w http.ResponseWriter, r *NinaRequest) error {
+		return NewHTTPError(http.StatusTeapot, "teapot", "I'm a teapot").WithDetails(map[string]string{"brew": "coffee"})
+	}), nil)
+	nr.GET("/boom", E(func(w http.ResponseWriter, r *NinaRequest) error {
+		return errors.New("database password is hunter2")
+	}), nil)
+	nr.GET("/invalid", E(func(w http.ResponseWriter, r *NinaRequest) error {
+		var dst struct {
+			Name string `query:"name" validate:"required"`
+		}
+		return r.Validate(&dst)
+	}), nil)
+	nr.GET("/typed", E(func(w http.ResponseWriter, r *NinaRequest) error {
+		return NewHTTPError(http.StatusPaymentRequired, "out_of_credit", "Your balance is 30, but that costs 50.").
+			WithType("https://example.com/probs/out-of-credit").
+			WithExtension("balance", 30)
+	}), nil)
+	nr.GET("/ok", E(func(w http.ResponseWriter, r *NinaRequest) error {
+		return r.Response(w).Text(http.StatusOK, "fine")
+	}), nil)
+	nr.GET("/late", E(func(w http.ResponseWriter, r *NinaRequest) error {
+		w.WriteHeader(http.StatusAccepted)
+		return errors.New("too late")
+	}), nil)
+
+	tests := []struct {
+		url        string
+		wantStatus int
+		wantCode   string
+		wantBody   string
+	}{
+		{"/teapot", http.StatusTeapot, "teapot", `{"code":"teapot","details":{"brew":"coffee"},"instance":"/teapot","status":418,"title":"I'm a teapot","type":"about:blank"}`},
+		{"/boom", http.StatusInternalServerError, "internal_error", `{"code":"internal_error","instance":"/boom","status":500,"title":"Internal Server Error","type":"about:blank"}`},
+		{"/invalid", http.StatusUnprocessableEntity, "validation_failed", `{"code":"validation_failed","detail":"Invalid request parameters","details":[{"field":"name","rule":"required","message":"is required"}],"instance":"/invalid","status":422,"title":"Unprocessable Entity","type":"about:blank"}`},
+		{"/typed", http.StatusPaymentRequired, "out_of_credit", `{"balance":30,"code":"out_of_credit","detail":"Your balance is 30, but that costs 50.","instance":"/typed","status":402,"title":"Payment Required","type":"https://example.com/probs/out-of-credit"}`},
+		{"/ok", http.StatusOK, "", "fine"},
+		{"/late", http.StatusAccepted, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			nr.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %v, want %v", rr.Code, tt.wantStatus)
+			}
+			if got := strings.TrimSpace(rr.Body.String()); got != tt.wantBody {
+				t.Errorf("got body %v, want %v", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestCustomErrorHandler(t *testing.T) {
+	nr := NewRouter()
+	nr.ErrorHandler = func(w http.ResponseWriter, r *NinaRequest, err error) {
+		httpErr := AsHTTPError(err)
+		w.WriteHeader(httpErr.Status)
+		fmt.Fprintf(w, "%s on %s", httpErr.Code, r.URL.Path)
+	}
+
+	nr.POST("/items", E(func(w http.ResponseWriter, r *NinaRequest) error {
+		if _, err := r.GetBody(); err != nil {
+			return err
+		}
+		return NewHTTPError(http.StatusConflict, "duplicate", "")
+	}), nil)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"Handler error", `{"name":"nina"}`, http.StatusConflict, "duplicate on /items"},
+		{"Body parse failure", `{"name":`, http.StatusBadRequest, "invalid_json on /items"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+
+			nr.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %v, want %v", rr.Code, tt.wantStatus)
+			}
+			if rr.Body.String() != tt.wantBody {
+				t.Errorf("got body %v, want %v", rr.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestErrorWithoutRouter(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req := &NinaRequest{Request: httptest.NewRequest(http.MethodGet, "/", nil)}
+
+	req.Error(rr, NewHTTPError(http.StatusForbidden, "forbidden", ""))
+
+	var body Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON body %q: %v", rr.Body.String(), err)
+	}
+	if rr.Code != http.StatusForbidden || body.Title != "Forbidden" || body.Extensions["code"] != "forbidden" {
+		t.Errorf("got %v %+v, want 403 forbidden", rr.Code, body)
+	}
+}
+
+func TestSwitchErrorFormatPerRouter(t *testing.T) {
+	failing := E(func(w http.ResponseWriter, r *NinaRequest) error {
+		return NewHTTPError(http.StatusConflict, "duplicate", "Already exists")
+	})
+
+	tests := []struct {
+		name            string
+		errorHandler    ErrorHandler
+		wantContentType string
+		wantBody        string
+	}{
+		{"Problem details", nil, ProblemContentType, `{"code":"duplicate","detail":"Already exists","instance":"/items","status":409,"title":"Conflict","type":"about:blank"}`},
+		{"Plain JSON", JSONErrorHandler, "application/json; charset=utf-8", `{"status":409,"code":"duplicate","message":"Already exists"}`},
+		{"Text", TextErrorHandler, "text/plain; charset=utf-8", "Already exists"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nr := NewRouter()
+			nr.ErrorHandler = tt.errorHandler
+			nr.GET("/items", failing, nil)
+
+			rr := httptest.NewRecorder()
+			nr.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/items", nil))
+
+			if rr.Code != http.StatusConflict {
+				t.Errorf("got status %v, want %v", rr.Code, http.StatusConflict)
+			}
+			if got := rr.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("got content type %v, want %v", got, tt.wantContentType)
+			}
+			if got := strings.TrimSpace(rr.Body.String()); got != tt.wantBody {
+				t.Errorf("got body %v, want %v", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestProblemJSONRoundTrip(t *testing.T) {
+	p := NewProblem(http.StatusNotFound, "no user 42")
+	p.Instance = "/users/42"
+	p.Extensions = map[string]interface{}{"status": "shadowed", "user": "42"}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"detail":"no user 42","instance":"/users/42","status":404,"title":"Not Found","type":"about:blank","user":"42"}`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+
+	var decoded Problem
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Status != http.StatusNotFound || decoded.Detail != "no user 42" || decoded.Extensions["user"] != "42" {
+		t.Errorf("got %+v after round trip", decoded)
+	}
+}
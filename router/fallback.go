@@ -0,0 +1,169 @@
+package router
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// order of the methods in the Allow header of 405 responses
+var standardMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodOptions,
+	http.MethodTrace,
+}
+
+// NotFound sets the handler used when no route matches the request path.
+// middlewares wrap it the same way they wrap a route.
+func (mux *ServeMux) NotFound(handler Handler, middlewares []Middleware) {
+	mux.notFound = mux.chain(applyMiddlewares(handler, middlewares...))
+}
+
+// MethodNotAllowed sets the handler used when the path matches a route but not
+// for the request method. The Allow header listing every method registered for
+// the path is already set when the handler runs.
+func (mux *ServeMux) MethodNotAllowed(handler Handler, middlewares []Middleware) {
+	mux.methodNotAllowed = mux.chain(applyMiddlewares(handler, middlewares...))
+}
+
+// ServeHTTP dispatches the request to the matching route, or to the NotFound
+// and MethodNotAllowed handlers when there is none.
+func (mux *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := mux.ServeMux.Handler(r); pattern != "" || r.RequestURI == "*" {
+		mux.ServeMux.ServeHTTP(w, r)
+		return
+	}
+
+	if allowed := mux.allowedMethods(r); len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		mux.serveFallback(w, r, mux.methodNotAllowed)
+		return
+	}
+	mux.serveFallback(w, r, mux.notFound)
+}
+
+// allowedMethods lists the methods that have a route for the request path,
+// routes whose constraints reject the path are not counted
+func (mux *ServeMux) allowedMethods(r *http.Request) []string {
+	matched := make(map[string]bool)
+	for _, route := range mux.methodRoutes {
+		if !matched[route.method] && route.matches(r) {
+			matched[route.method] = true
+		}
+	}
+	// GET routes answer HEAD requests too
+	if matched[http.MethodGet] {
+		matched[http.MethodHead] = true
+	}
+
+	var allowed []string
+	for _, method := range append(standardMethods, mux.customMethods...) {
+		if method != r.Method && matched[method] {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
+
+// methodRoute is the path of a route registered for method, split into
+// segments when the route is registered
+type methodRoute struct {
+	method   string
+	host     string
+	segments []string // literal segments, {} and {...} for the params
+	params   []routeParam
+	// the pattern ends with a slash and matches every path below it
+	prefix bool
+}
+
+func newMethodRoute(method string, rp routePattern) methodRoute {
+	route := methodRoute{method: method, params: rp.params}
+	path := rp.shape
+	if i := strings.IndexByte(path, '/'); i > 0 {
+		route.host, path = path[:i], path[i:]
+	}
+	route.segments = strings.Split(strings.TrimPrefix(path, "/"), "/")
+	switch last := len(route.segments) - 1; route.segments[last] {
+	case "{$}":
+		route.segments[last] = ""
+	case "":
+		route.segments, route.prefix = route.segments[:last], true
+	}
+	return route
+}
+
+// matches reports whether the path of r matches the route the way
+// http.ServeMux does, with the constraints of the params checked
+func (route methodRoute) matches(r *http.Request) bool {
+	if route.host != "" && route.host != requestHost(r) {
+		return false
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/")
+	var values []string
+	for i, segment := range route.segments {
+		if segment == "{...}" {
+			rest, err := url.PathUnescape(strings.Join(parts[i:], "/"))
+			if err != nil {
+				return false
+			}
+			values = append(values, rest)
+			return routeCandidate{params: route.params}.matches(values)
+		}
+		if i >= len(parts) {
+			return false
+		}
+		part, err := url.PathUnescape(parts[i])
+		if err != nil {
+			return false
+		}
+		switch {
+		case segment == "{}" && part != "":
+			values = append(values, part)
+		case segment != part:
+			return false
+		}
+	}
+	if len(parts) > len(route.segments) && !route.prefix {
+		return false
+	}
+	return routeCandidate{params: route.params}.matches(values)
+}
+
+func requestHost(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.Host); err == nil {
+		return host
+	}
+	return r.Host
+}
+
+// serveFallback serves r with handler, already wrapped by the router middlewares
+func (mux *ServeMux) serveFallback(w http.ResponseWriter, r *http.Request, handler Handler) {
+	ninaRequest := newNinaRequest(r, nil, nil)
+	ninaRequest.router = mux
+	handler(NewResponse(w, ninaRequest), ninaRequest)
+}
+
+func (mux *ServeMux) trackMethod(method string) {
+	for _, known := range append(standardMethods, mux.customMethods...) {
+		if known == method {
+			return
+		}
+	}
+	mux.customMethods = append(mux.customMethods, method)
+}
+
+func defaultNotFound(w http.ResponseWriter, r *NinaRequest) {
+	r.Error(w, NewHTTPError(http.StatusNotFound, "not_found", ""))
+}
+
+func defaultMethodNotAllowed(w http.ResponseWriter, r *NinaRequest) {
+	r.Error(w, NewHTTPError(http.StatusMethodNotAllowed, "method_not_allowed", "").
+		WithExtension("allow", strings.Split(w.Header().Get("Allow"), ", ")))
+}
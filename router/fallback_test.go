@@ -0,0 +1,133 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNotFoundAndMethodNotAllowed(t *testing.T) {
+	nr := NewRouter()
+
+	var calls []string
+	trace := func(next Handler) Handler {
+		return func(w http.ResponseWriter, r *NinaRequest) {
+			calls = append(calls, "trace "+r.Method+" "+r.URL.Path)
+			next(w, r)
+		}
+	}
+
+	okHandler := func(w http.ResponseWriter, r *NinaRequest) {
+		w.Write([]byte("ok"))
+	}
+	nr.GET("/users/{id}", okHandler, nil)
+	nr.PUT("/users/{id}", okHandler, nil)
+	nr.Handle([]string{"PURGE"}, "/users/{id}", okHandler, nil)
+	nr.POST("/users", okHandler, nil)
+
+	nr.NotFound(func(w http.ResponseWriter, r *NinaRequest) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("nothing at " + r.URL.Path))
+	}, []Middleware{trace})
+	nr.MethodNotAllowed(func(w http.ResponseWriter, r *NinaRequest) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("use " + w.Header().Get("Allow")))
+	}, []Middleware{trace})
+
+	tests := []struct {
+		method     string
+		url        string
+		wantStatus int
+		wantAllow  string
+		wantBody   string
+	}{
+		{"GET", "/users/1", http.StatusOK, "", "ok"},
+		{"GET", "/nope", http.StatusNotFound, "", "nothing at /nope"},
+		{"DELETE", "/users/1", http.StatusMethodNotAllowed, "GET, HEAD, PUT, PURGE", "use GET, HEAD, PUT, PURGE"},
+		{"GET", "/users", http.StatusMethodNotAllowed, "POST", "use POST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.url, func(t *testing.T) {
+			calls = nil
+			rr := httptest.NewRecorder()
+			nr.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.url, nil))
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %v, want %v", rr.Code, tt.wantStatus)
+			}
+			if got := rr.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("got Allow %q, want %q", got, tt.wantAllow)
+			}
+			if rr.Body.String() != tt.wantBody {
+				t.Errorf("got body %v, want %v", rr.Body.String(), tt.wantBody)
+			}
+			if tt.wantStatus != http.StatusOK && len(calls) != 1 {
+				t.Errorf("fallback did not run through its middlewares, calls: %v", calls)
+			}
+		})
+	}
+}
+
+func TestDefaultMethodNotAllowed(t *testing.T) {
+	nr := NewRouter()
+	nr.POST("/items", func(w http.ResponseWriter, r *NinaRequest) {}, nil)
+
+	rr := httptest.NewRecorder()
+	nr.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/items", nil))
+
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("got status %v, want %v", rr.Code, http.StatusMethodNotAllowed)
+	}
+	if rr.Header().Get("Allow") != "POST" {
+		t.Errorf("got Allow %q, want POST", rr.Header().Get("Allow"))
+	}
+	want := `{"allow":["POST"],"code":"method_not_allowed","instance":"/items","status":405,"title":"Method Not Allowed","type":"about:blank"}`
+	if got := strings.TrimSpace(rr.Body.String()); got != want {
+		t.Errorf("got body %v, want %v", got, want)
+	}
+}
+
+func TestMethodNotAllowedMatchesRoutes(t *testing.T) {
+	nr := NewRouter()
+	ok := func(w http.ResponseWriter, r *NinaRequest) {}
+	nr.GET("/users/{id:int}", ok, nil)
+	nr.DELETE("/users/{slug:[a-z]+}", ok, nil)
+	nr.PUT("/files/{path...}", ok, nil)
+	nr.POST("/static/", ok, nil)
+	nr.PATCH("/exact/{$}", ok, nil)
+	nr.GET("example.com/hosted", ok, nil)
+
+	tests := []struct {
+		method     string
+		url        string
+		wantStatus int
+		wantAllow  string
+	}{
+		{"POST", "/users/1", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{"POST", "/users/abc", http.StatusMethodNotAllowed, "DELETE"},
+		{"POST", "/users/a1", http.StatusNotFound, ""},
+		{"GET", "/users/a1", http.StatusNotFound, ""},
+		{"GET", "/files/a/b.txt", http.StatusMethodNotAllowed, "PUT"},
+		{"GET", "/static/css/site.css", http.StatusMethodNotAllowed, "POST"},
+		{"GET", "/exact/", http.StatusMethodNotAllowed, "PATCH"},
+		{"GET", "/exact/more", http.StatusNotFound, ""},
+		{"POST", "http://example.com:8080/hosted", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{"POST", "http://other.com/hosted", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.url, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			nr.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.url, nil))
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %v, want %v", rr.Code, tt.wantStatus)
+			}
+			if got := rr.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("got Allow %q, want %q", got, tt.wantAllow)
+			}
+		})
+	}
+}
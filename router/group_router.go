@@ -1,6 +1,9 @@
 package router
 
-import "net/http"
+import (
+	"net/http"
+	"strings"
+)
 
 type Group struct {
 	prefix          string
@@ -18,61 +21,123 @@ func (mux *ServeMux) GROUP(prefix string, preMiddlewares []Middleware, postMiddl
 	}
 }
 
-func (g *Group) addRoute(method, path string, handler Handler, middlewares []Middleware) {
+// GROUP creates a sub-group whose prefix and middlewares are composed with the
+// parent ones. The parent pre middlewares run before the child ones and the
+// parent post middlewares run after, so the child group is wrapped by its parent
+// exactly like a single route would be.
+func (g *Group) GROUP(prefix string, preMiddlewares []Middleware, postMiddlewares []Middleware) *Group {
+	pre := make([]Middleware, 0, len(g.preMiddlewares)+len(preMiddlewares))
+	pre = append(pre, g.preMiddlewares...)
+	pre = append(pre, preMiddlewares...)
+
+	post := make([]Middleware, 0, len(postMiddlewares)+len(g.postMiddlewares))
+	post = append(post, postMiddlewares...)
+	post = append(post, g.postMiddlewares...)
+
+	return &Group{
+		prefix:          g.prefix + prefix,
+		router:          g.router,
+		preMiddlewares:  pre,
+		postMiddlewares: post,
+	}
+}
+
+// Mount serves every request under prefix with sub. The prefix is stripped
+// before sub sees the request, so a module router can be built and tested on
+// its own and stitched into the main application later.
+func (mux *ServeMux) Mount(prefix string, sub *ServeMux) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" || !strings.HasPrefix(prefix, "/") {
+		panic("router: mount prefix must start with '/' and not be the root")
+	}
+	if sub == nil || sub == mux {
+		panic("router: invalid sub router for mount on " + prefix)
+	}
+	mux.mounts = append(mux.mounts, mountPoint{prefix: prefix, sub: sub})
+	if sub.parent == nil {
+		// URL on sub includes the prefix of its first mount
+		sub.parent, sub.mountPrefix = mux, prefix
+	}
+	stripped := http.StripPrefix(prefix, sub)
+	// Requests go through the parent router middlewares before reaching sub
+	serveSub := mux.chain(func(w http.ResponseWriter, r *NinaRequest) {
+		stripped.ServeHTTP(w, r.Request)
+	})
+	mux.ServeMux.Handle(prefix+"/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ninaRequest := newNinaRequest(r, nil, nil)
+		ninaRequest.router = mux
+		serveSub(NewResponse(w, ninaRequest), ninaRequest)
+	}))
+}
+
+func (g *Group) addRoute(methods []string, path string, handler Handler, middlewares []Middleware) *Route {
 	fullPath := g.prefix + path
-	allMiddlewares := append(g.preMiddlewares, middlewares...)
+	// Build a fresh slice so routes never share the group's backing arrays
+	allMiddlewares := make([]Middleware, 0, len(g.preMiddlewares)+len(middlewares)+len(g.postMiddlewares))
+	allMiddlewares = append(allMiddlewares, g.preMiddlewares...)
+	allMiddlewares = append(allMiddlewares, middlewares...)
 	allMiddlewares = append(allMiddlewares, g.postMiddlewares...)
-	finalHandler := applyMiddlewares(handler, allMiddlewares...)
-	g.router.ServeMux.Handle(method+" "+fullPath, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ninaRequest := &NinaRequest{
-			Request:       r,
-			Header:        r.Header,
-			Form:          &r.Form,
-			Method:        r.Method,
-			PostForm:      &r.PostForm,
-			ctx:           r.Context(),
-			ContentLength: r.ContentLength,
-			tls:           r.TLS,
-			Proto:         r.Proto,
-			Host:          r.Host,
-			UserAgent:     r.UserAgent(),
-		}
-		finalHandler(w, ninaRequest)
-	}))
+	// Same pipeline as top-level routes, params are extracted against the full prefixed pattern
+	return g.router.Handle(methods, fullPath, handler, allMiddlewares)
+}
+
+// Handle registers handler for every method in methods on the group prefix plus path.
+func (g *Group) Handle(methods []string, path string, handler Handler, middlewares []Middleware) *Route {
+	return g.addRoute(methods, path, handler, middlewares)
+}
+
+// HandleE is Handle for a HandlerE, see ServeMux.HandleE.
+func (g *Group) HandleE(methods []string, path string, h HandlerE, middlewares []Middleware) *Route {
+	route := g.addRoute(methods, path, E(h), middlewares)
+	route.handlerName = funcName(h)
+	return route
+}
+
+// Match registers handler for the given set of methods on the group prefix plus path.
+func (g *Group) Match(methods []string, path string, handler Handler, middlewares []Middleware) *Route {
+	if len(methods) == 0 {
+		panic("router: Match requires at least one method")
+	}
+	return g.addRoute(methods, path, handler, middlewares)
+}
+
+// ANY registers handler on the group prefix plus path for every HTTP method.
+func (g *Group) ANY(path string, handler Handler, middlewares []Middleware) *Route {
+	return g.addRoute(nil, path, handler, middlewares)
 }
 
-func (g *Group) GET(path string, handler Handler, middlewares []Middleware) {
-	g.addRoute(http.MethodGet, path, handler, middlewares)
+func (g *Group) GET(path string, handler Handler, middlewares []Middleware) *Route {
+	return g.addRoute([]string{http.MethodGet}, path, handler, middlewares)
 }
 
-func (g *Group) POST(path string, handler Handler, middlewares []Middleware) {
-	g.addRoute(http.MethodPost, path, handler, middlewares)
+func (g *Group) POST(path string, handler Handler, middlewares []Middleware) *Route {
+	return g.addRoute([]string{http.MethodPost}, path, handler, middlewares)
 }
 
-func (g *Group) PUT(path string, handler Handler, middlewares []Middleware) {
-	g.addRoute(http.MethodPut, path, handler, middlewares)
+func (g *Group) PUT(path string, handler Handler, middlewares []Middleware) *Route {
+	return g.addRoute([]string{http.MethodPut}, path, handler, middlewares)
 }
 
-func (g *Group) DELETE(path string, handler Handler, middlewares []Middleware) {
-	g.addRoute(http.MethodDelete, path, handler, middlewares)
+func (g *Group) DELETE(path string, handler Handler, middlewares []Middleware) *Route {
+	return g.addRoute([]string{http.MethodDelete}, path, handler, middlewares)
 }
 
-func (g *Group) HEAD(path string, handler Handler, middlewares []Middleware) {
-	g.addRoute(http.MethodHead, path, handler, middlewares)
+func (g *Group) HEAD(path string, handler Handler, middlewares []Middleware) *Route {
+	return g.addRoute([]string{http.MethodHead}, path, handler, middlewares)
 }
 
-func (g *Group) PATCH(path string, handler Handler, middlewares []Middleware) {
-	g.addRoute(http.MethodPatch, path, handler, middlewares)
+func (g *Group) PATCH(path string, handler Handler, middlewares []Middleware) *Route {
+	return g.addRoute([]string{http.MethodPatch}, path, handler, middlewares)
 }
 
-func (g *Group) OPTIONS(path string, handler Handler, middlewares []Middleware) {
-	g.addRoute(http.MethodOptions, path, handler, middlewares)
+func (g *Group) OPTIONS(path string, handler Handler, middlewares []Middleware) *Route {
+	return g.addRoute([]string{http.MethodOptions}, path, handler, middlewares)
 }
 
-func (g *Group) CONNECT(path string, handler Handler, middlewares []Middleware) {
-	g.addRoute(http.MethodConnect, path, handler, middlewares)
+func (g *Group) CONNECT(path string, handler Handler, middlewares []Middleware) *Route {
+	return g.addRoute([]string{http.MethodConnect}, path, handler, middlewares)
 }
 
-func (g *Group) TRACE(path string, handler Handler, middlewares []Middleware) {
-	g.addRoute(http.MethodTrace, path, handler, middlewares)
+func (g *Group) TRACE(path string, handler Handler, middlewares []Middleware) *Route {
+	return g.addRoute([]string{http.MethodTrace}, path, handler, middlewares)
 }
@@ -1,8 +1,10 @@
 package router
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -35,10 +37,10 @@ func TestGroupRouter(t *testing.T) {
 		{"POST", "/api/hello", http.StatusOK, "Hello, World!"},
 		{"PUT", "/api/hello", http.StatusOK, "Hello, World!"},
 		{"DELETE", "/api/hello", http.StatusOK, "Hello, World!"},
-		{"GET", "/hello", http.StatusNotFound, "404 page not found\n"},
-		{"POST", "/hello", http.StatusNotFound, "404 page not found\n"},
-		{"PUT", "/hello", http.StatusNotFound, "404 page not found\n"},
-		{"DELETE", "/hello", http.StatusNotFound, "404 page not found\n"},
+		{"GET", "/hello", http.StatusNotFound, notFoundBody("/hello")},
+		{"POST", "/hello", http.StatusNotFound, notFoundBody("/hello")},
+		{"PUT", "/hello", http.StatusNotFound, notFoundBody("/hello")},
+		{"DELETE", "/hello", http.StatusNotFound, notFoundBody("/hello")},
 	}
 
 	for _, tt := range tests {
@@ -58,3 +60,141 @@ func TestGroupRouter(t *testing.T) {
 		})
 	}
 }
+
+func TestGroupRouterRequestPipeline(t *testing.T) {
+	nr := NewRouter()
+	group := nr.GROUP("/admin", nil, nil)
+
+	group.POST("/users/{id}", func(w http.ResponseWriter, r *NinaRequest) {
+		body, err := r.GetBody()
+		if err != nil {
+			r.Error(w, err)
+			return
+		}
+		fmt.Fprintf(w, "%s|%s|%s|%v", r.Params.UriParams["id"], r.Params.QueryString["q"], r.RemoteAddr, body["name"])
+	}, nil)
+	group.GET("/users/{id}", func(w http.ResponseWriter, r *NinaRequest) {
+		fmt.Fprintf(w, "%s|%s", r.Params.Params["id"], r.Params.Params["q"])
+	}, nil)
+
+	tests := []struct {
+		name        string
+		method      string
+		url         string
+		contentType string
+		body        string
+		wantStatus  int
+		wantBody    string
+	}{
+		{"GET params", "GET", "/admin/users/7?q=x", "", "", http.StatusOK, "7|x"},
+		{"POST JSON", "POST", "/admin/users/7?q=x", "application/json", `{"name":"nina"}`, http.StatusOK, "7|x|10.0.0.1:1234|nina"},
+		{"POST XML", "POST", "/admin/users/7", "application/xml", `<name>nina</name>`, http.StatusOK, "7||10.0.0.1:1234|nina"},
+		{"POST form", "POST", "/admin/users/7", "application/x-www-form-urlencoded", "name=nina", http.StatusOK, "7||10.0.0.1:1234|nina"},
+		{"POST invalid JSON", "POST", "/admin/users/7", "application/json", `{`, http.StatusBadRequest, `{"code":"invalid_json","detail":"Invalid JSON","instance":"/admin/users/7","offset":1,"status":400,"title":"Bad Request","type":"about:blank"}` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			req.RemoteAddr = "10.0.0.1:1234"
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rr := httptest.NewRecorder()
+
+			nr.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %v, want %v", rr.Code, tt.wantStatus)
+			}
+			if rr.Body.String() != tt.wantBody {
+				t.Errorf("got body %v, want %v", rr.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestNestedGroups(t *testing.T) {
+	nr := NewRouter()
+
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(w http.ResponseWriter, r *NinaRequest) {
+				calls = append(calls, name)
+				next(w, r)
+			}
+		}
+	}
+
+	api := nr.GROUP("/api", []Middleware{trace("api-pre")}, []Middleware{trace("api-post")})
+	v1 := api.GROUP("/v1", []Middleware{trace("v1-pre")}, []Middleware{trace("v1-post")})
+	v1.GET("/users/{id}", func(w http.ResponseWriter, r *NinaRequest) {
+		calls = append(calls, "handler")
+		w.Write([]byte(r.Params.UriParams["id"]))
+	}, []Middleware{trace("route")})
+
+	req := httptest.NewRequest("GET", "/api/v1/users/42", nil)
+	rr := httptest.NewRecorder()
+	nr.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || rr.Body.String() != "42" {
+		t.Fatalf("got %v %q, want 200 \"42\"", rr.Code, rr.Body.String())
+	}
+
+	want := "api-pre,v1-pre,route,v1-post,api-post,handler"
+	if got := strings.Join(calls, ","); got != want {
+		t.Errorf("got middleware order %v, want %v", got, want)
+	}
+}
+
+func TestMount(t *testing.T) {
+	users := NewRouter()
+	users.GET("/{id}", func(w http.ResponseWriter, r *NinaRequest) {
+		fmt.Fprintf(w, "user %s at %s", r.Params.UriParams["id"], r.URL.Path)
+	}, nil)
+
+	billing := NewRouter()
+	billing.POST("/invoices", func(w http.ResponseWriter, r *NinaRequest) {
+		body, _ := r.GetBody()
+		fmt.Fprintf(w, "invoice %v", body["amount"])
+	}, nil)
+
+	app := NewRouter()
+	app.Mount("/api/v1/users", users)
+	app.Mount("/api/v1/billing/", billing)
+
+	tests := []struct {
+		method     string
+		url        string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"GET", "/api/v1/users/7", "", http.StatusOK, "user 7 at /7"},
+		{"POST", "/api/v1/billing/invoices", `{"amount":10}`, http.StatusOK, "invoice 10"},
+		{"GET", "/users/7", "", http.StatusNotFound, notFoundBody("/users/7")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.url, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+
+			app.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %v, want %v", rr.Code, tt.wantStatus)
+			}
+			if rr.Body.String() != tt.wantBody {
+				t.Errorf("got body %v, want %v", rr.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+// notFoundBody is the problem details sent by the default NotFound handler
+func notFoundBody(path string) string {
+	return `{"code":"not_found","instance":"` + path + `","status":404,"title":"Not Found","type":"about:blank"}` + "\n"
+}
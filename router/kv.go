@@ -0,0 +1,263 @@
+package router
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// KVContentType is the media type of the key=value body format:
+//
+//	body   = [pair *(sep pair)] [sep]
+//	pair   = key "=" value
+//	sep    = "," / ";" / "|"
+//	key    = quoted / bare    ; a bare key stops at "=" or a separator
+//	value  = quoted / bare    ; a bare value stops at a separator
+//	quoted = DQUOTE *(char / "\" char) DQUOTE
+//	bare   = *(char / "\" char)
+//
+// Whitespace around keys, values and separators is ignored, a backslash
+// makes the next character literal in bare and quoted strings alike.
+const KVContentType = "text/x-nina-kv"
+
+// KVDuplicates is what KVCodec does with a key found twice.
+type KVDuplicates int
+
+const (
+	KVKeepLast  KVDuplicates = iota // later values overwrite earlier ones
+	KVKeepFirst                     // later values are ignored
+	KVReject                        // the body is rejected with a KVSyntaxError
+)
+
+// KVSyntaxError reports where a key=value body is malformed. Offset is the
+// byte offset of the problem in the body.
+type KVSyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *KVSyntaxError) Error() string {
+	return fmt.Sprintf("kv: %s at offset %d", e.Msg, e.Offset)
+}
+
+// KVCodec parses and encodes the text/x-nina-kv format. The zero value keeps
+// the last of duplicate keys and requires "=" in every pair, it is the
+// decoder registered for KVContentType.
+type KVCodec struct {
+	Duplicates KVDuplicates
+	// BareKeys accepts a key without "=", with an empty value
+	BareKeys bool
+}
+
+// Decode implements Decoder. v can be a *map[string]interface{}, a
+// *map[string]string or a pointer to a struct using form tags.
+func (c KVCodec) Decode(data []byte, v interface{}) error {
+	values, err := c.Parse(string(data))
+	if err != nil {
+		return err
+	}
+	switch dst := v.(type) {
+	case *map[string]interface{}:
+		*dst = make(map[string]interface{}, len(values))
+		for key, value := range values {
+			(*dst)[key] = value
+		}
+		return nil
+	case *map[string]string:
+		*dst = values
+		return nil
+	default:
+		form := make(map[string][]string, len(values))
+		for key, value := range values {
+			form[key] = []string{value}
+		}
+		return bindValues(v, tagForm, form)
+	}
+}
+
+// Parse parses a key=value body, errors are *KVSyntaxError.
+func (c KVCodec) Parse(s string) (map[string]string, error) {
+	result := make(map[string]string)
+	p := &kvParser{s: s}
+
+	for {
+		p.skipSpaces()
+		if p.pos == len(s) {
+			return result, nil
+		}
+
+		keyOffset := p.pos
+		key, quoted, err := p.token(true)
+		if err != nil {
+			return nil, err
+		}
+		if key == "" && !quoted {
+			return nil, &KVSyntaxError{Offset: keyOffset, Msg: "missing key"}
+		}
+
+		var value string
+		p.skipSpaces()
+		if p.pos < len(s) && s[p.pos] == '=' {
+			p.pos++
+			p.skipSpaces()
+			if value, _, err = p.token(false); err != nil {
+				return nil, err
+			}
+		} else if !c.BareKeys {
+			return nil, &KVSyntaxError{Offset: p.pos, Msg: fmt.Sprintf("missing '=' after key %q", key)}
+		}
+
+		p.skipSpaces()
+		if p.pos < len(s) {
+			if !isKVSeparator(s[p.pos]) {
+				r, _ := utf8.DecodeRuneInString(s[p.pos:])
+				return nil, &KVSyntaxError{Offset: p.pos, Msg: fmt.Sprintf("unexpected %q after value", r)}
+			}
+			p.pos++
+		}
+
+		if _, found := result[key]; found {
+			switch c.Duplicates {
+			case KVKeepFirst:
+				continue
+			case KVReject:
+				return nil, &KVSyntaxError{Offset: keyOffset, Msg: fmt.Sprintf("duplicate key %q", key)}
+			}
+		}
+		result[key] = value
+	}
+}
+
+// Encode writes values in the format read by Parse, keys are sorted so the
+// output is stable.
+func (c KVCodec) Encode(values map[string]string) []byte {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		writeKVString(&b, key, key == "")
+		b.WriteByte('=')
+		writeKVString(&b, values[key], false)
+	}
+	return []byte(b.String())
+}
+
+// KV sends values in the text/x-nina-kv format.
+func (res *NinaResponse) KV(status int, values map[string]string) error {
+	return res.Blob(status, KVContentType+"; charset=utf-8", KVCodec{}.Encode(values))
+}
+
+type kvParser struct {
+	s   string
+	pos int
+}
+
+func (p *kvParser) skipSpaces() {
+	for p.pos < len(p.s) && isKVSpace(p.s[p.pos]) {
+		p.pos++
+	}
+}
+
+// token reads a quoted or bare string, a bare key also stops at "="
+func (p *kvParser) token(isKey bool) (string, bool, error) {
+	if p.pos < len(p.s) && p.s[p.pos] == '"' {
+		value, err := p.quoted()
+		return value, true, err
+	}
+
+	var b strings.Builder
+	keep := 0 // escaped characters are never trimmed
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if isKVSeparator(c) || (isKey && c == '=') {
+			break
+		}
+		if c == '\\' {
+			if err := p.escaped(&b); err != nil {
+				return "", false, err
+			}
+			keep = b.Len()
+			continue
+		}
+		b.WriteByte(c)
+		p.pos++
+	}
+
+	value := b.String()
+	trimmed := strings.TrimRightFunc(value[keep:], func(r rune) bool { return r < utf8.RuneSelf && isKVSpace(byte(r)) })
+	return value[:keep] + trimmed, false, nil
+}
+
+func (p *kvParser) quoted() (string, error) {
+	start := p.pos
+	p.pos++ // opening quote
+
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		switch c := p.s[p.pos]; c {
+		case '"':
+			p.pos++
+			return b.String(), nil
+		case '\\':
+			if err := p.escaped(&b); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", &KVSyntaxError{Offset: start, Msg: "unterminated quoted string"}
+}
+
+// escaped writes the character following a backslash
+func (p *kvParser) escaped(b *strings.Builder) error {
+	if p.pos+1 >= len(p.s) {
+		return &KVSyntaxError{Offset: p.pos, Msg: "unfinished escape sequence"}
+	}
+	r, size := utf8.DecodeRuneInString(p.s[p.pos+1:])
+	b.WriteRune(r)
+	p.pos += 1 + size
+	return nil
+}
+
+func writeKVString(b *strings.Builder, s string, forceQuotes bool) {
+	if !forceQuotes && !kvNeedsQuotes(s) {
+		b.WriteString(s)
+		return
+	}
+	b.WriteByte('"')
+	for _, r := range s {
+		if r == '"' || r == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+}
+
+func kvNeedsQuotes(s string) bool {
+	if s == "" {
+		return false
+	}
+	if isKVSpace(s[0]) || isKVSpace(s[len(s)-1]) {
+		return true
+	}
+	return strings.ContainsAny(s, "\",;|=\\")
+}
+
+func isKVSeparator(c byte) bool {
+	return c == ',' || c == ';' || c == '|'
+}
+
+func isKVSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
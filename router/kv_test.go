@@ -0,0 +1,146 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKVParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec KVCodec
+		body  string
+		want  map[string]string
+	}{
+		{"Separators", KVCodec{}, "a=1,b=2;c=3|d=4", map[string]string{"a": "1", "b": "2", "c": "3", "d": "4"}},
+		{"Whitespace and trailing separator", KVCodec{}, " a = 1 ,\n b=two words ; ", map[string]string{"a": "1", "b": "two words"}},
+		{"Escapes", KVCodec{}, `a\,b=1\;2,c=x\ `, map[string]string{"a,b": "1;2", "c": "x "}},
+		{"Quoted", KVCodec{}, `"key=1"=" padded, \"quoted\" ",empty=""`, map[string]string{"key=1": ` padded, "quoted" `, "empty": ""}},
+		{"Equals in value", KVCodec{}, "url=a=b", map[string]string{"url": "a=b"}},
+		{"Empty", KVCodec{}, "", map[string]string{}},
+		{"Keep last", KVCodec{}, "a=1,a=2", map[string]string{"a": "2"}},
+		{"Keep first", KVCodec{Duplicates: KVKeepFirst}, "a=1,a=2", map[string]string{"a": "1"}},
+		{"Bare keys", KVCodec{BareKeys: true}, "hello world", map[string]string{"hello world": ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.codec.Parse(tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKVParseErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		codec      KVCodec
+		body       string
+		wantOffset int
+		wantMsg    string
+	}{
+		{"Missing separator", KVCodec{}, "a=1,b,c=3", 5, `missing '=' after key "b"`},
+		{"Missing key", KVCodec{}, "a=1,,b=2", 4, "missing key"},
+		{"Unterminated quote", KVCodec{}, `a="open`, 2, "unterminated quoted string"},
+		{"Unfinished escape", KVCodec{}, `a=1\`, 3, "unfinished escape sequence"},
+		{"Text after quote", KVCodec{}, `a="1"x`, 5, `unexpected 'x' after value`},
+		{"Duplicate", KVCodec{Duplicates: KVReject}, "a=1, a=2", 5, `duplicate key "a"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.codec.Parse(tt.body)
+			var syntaxErr *KVSyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("got %v, want a KVSyntaxError", err)
+			}
+			if syntaxErr.Offset != tt.wantOffset || syntaxErr.Msg != tt.wantMsg {
+				t.Errorf("got %q at %d, want %q at %d", syntaxErr.Msg, syntaxErr.Offset, tt.wantMsg, tt.wantOffset)
+			}
+		})
+	}
+}
+
+func TestKVEncodeRoundTrip(t *testing.T) {
+	values := map[string]string{
+		"plain":  "value",
+		"a,b":    "x=y",
+		"quotes": `say "hi" \o/`,
+		"spaces": " padded ",
+		"empty":  "",
+		"":       "empty key",
+	}
+
+	encoded := KVCodec{}.Encode(values)
+	want := `""=empty key,"a,b"="x=y",empty=,plain=value,quotes="say \"hi\" \\o/",spaces=" padded "`
+	if string(encoded) != want {
+		t.Errorf("got %s, want %s", encoded, want)
+	}
+
+	decoded, err := KVCodec{Duplicates: KVReject}.Parse(string(encoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, values) {
+		t.Errorf("got %v after round trip, want %v", decoded, values)
+	}
+}
+
+func TestKVBody(t *testing.T) {
+	nr := NewRouter()
+	nr.POST("/kv", E(func(w http.ResponseWriter, r *NinaRequest) error {
+		var dst struct {
+			Name string `form:"name"`
+			Age  int    `form:"age"`
+		}
+		if err := r.Bind(&dst); err != nil {
+			return err
+		}
+		body, _ := r.GetBody()
+		return r.Response(w).KV(http.StatusOK, map[string]string{"name": dst.Name, "greeting": body["greeting"].(string)})
+	}), nil)
+
+	tests := []struct {
+		name            string
+		contentType     string
+		body            string
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{"Codec", KVContentType, `name=nina;age=3|greeting="hi, there"`, http.StatusOK, "text/x-nina-kv; charset=utf-8", `greeting="hi, there",name=nina`},
+		{"Raw fallback", "text/plain", `name=nina,greeting=hi`, http.StatusOK, "text/x-nina-kv; charset=utf-8", `greeting=hi,name=nina`},
+		{"Codec error offset", KVContentType, `name=nina,age`, http.StatusBadRequest, ProblemContentType, `"offset":13`},
+		{"Raw error offset", "text/plain", `name="nina`, http.StatusBadRequest, ProblemContentType, `"offset":5`},
+		{"Raw plain text", "text/plain", `"hello" world`, http.StatusBadRequest, ProblemContentType, `"detail":"Unable to parse raw body"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/kv", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rr := httptest.NewRecorder()
+
+			nr.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %v, want %v: %v", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if got := rr.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("got content type %v, want %v", got, tt.wantContentType)
+			}
+			if !strings.Contains(rr.Body.String(), tt.wantBody) {
+				t.Errorf("got body %v, want %v", rr.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,433 @@
+package router
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMultipartMemory is the part of a multipart form kept in memory by
+// ParseMultipart, bigger files are stored in temporary files.
+const DefaultMultipartMemory = 32 << 20
+
+var (
+	// ErrFileTooLarge is reported when an uploaded file exceeds MaxFileSize.
+	ErrFileTooLarge = errors.New("uploaded file is too large")
+	// ErrBodyTooLarge is reported when the request body exceeds the allowed size.
+	ErrBodyTooLarge = errors.New("request body is too large")
+	// ErrMediaTypeNotAllowed is reported when an uploaded file type is not allowed.
+	ErrMediaTypeNotAllowed = errors.New("uploaded file type is not allowed")
+	// ErrNotMultipart is reported when the request is not multipart/form-data.
+	ErrNotMultipart = errors.New("request is not multipart/form-data")
+)
+
+// MultipartOptions limits what a multipart request may upload. Zero values
+// mean no limit, except MaxMemory which defaults to DefaultMultipartMemory.
+type MultipartOptions struct {
+	MaxFileSize  int64    // per file, in bytes
+	MaxTotalSize int64    // whole request body, in bytes
+	MaxMemory    int64    // kept in memory by ParseMultipart before spilling to disk
+	AllowedTypes []string // media types like "image/png" or "image/*"
+}
+
+// FilePart is a file being streamed by EachPart. Reads are limited to
+// MaxFileSize and fail with ErrFileTooLarge past it.
+type FilePart struct {
+	*multipart.Part
+	ContentType string // declared or sniffed media type of the file
+	reader      io.Reader
+}
+
+func (p *FilePart) Read(b []byte) (int, error) {
+	return p.reader.Read(b)
+}
+
+// SaveTo writes the part to the file at path.
+func (p *FilePart) SaveTo(path string) (int64, error) {
+	return saveReader(p, path)
+}
+
+// ParseMultipart parses a multipart/form-data body into MultipartForm,
+// enforcing the limits of opts. Files over MaxMemory are stored on disk and
+// files over MaxFileSize are refused while they are read. When the body was
+// already parsed, by Bind for instance, the files are checked against opts
+// again.
+func (nr *NinaRequest) ParseMultipart(opts MultipartOptions) error {
+	if nr.MultipartForm != nil {
+		return checkMultipartForm(nr.MultipartForm, opts)
+	}
+	if nr.router.bodyKind(nr.Header.Get("Content-Type")) != bodyMultipart {
+		return NewHTTPError(http.StatusUnsupportedMediaType, "not_multipart", "").Wrap(ErrNotMultipart)
+	}
+
+	maxMemory := opts.MaxMemory
+	if maxMemory <= 0 {
+		maxMemory = DefaultMultipartMemory
+	}
+	if opts.MaxTotalSize > 0 {
+		nr.Request.Body = http.MaxBytesReader(nil, nr.Request.Body, opts.MaxTotalSize)
+	}
+
+	if opts.MaxFileSize > 0 {
+		if err := nr.parseLimitedMultipart(maxMemory, opts.MaxFileSize); err != nil {
+			return invalidMultipart(err)
+		}
+	} else if err := nr.Request.ParseMultipartForm(maxMemory); err != nil {
+		return invalidMultipart(err)
+	}
+	form := nr.Request.MultipartForm
+
+	if err := checkMultipartForm(form, opts); err != nil {
+		form.RemoveAll()
+		return err
+	}
+
+	nr.MultipartForm = form
+	if nr.Params != nil {
+		nr.Params.form = form.Value
+	}
+	return nil
+}
+
+// parseLimitedMultipart is ParseMultipartForm failing with ErrFileTooLarge as
+// soon as a file goes over maxFileSize, before it is spooled. The parts are
+// copied through a pipe by a goroutine that limits the file parts.
+func (nr *NinaRequest) parseLimitedMultipart(maxMemory, maxFileSize int64) error {
+	reader, err := nr.Request.MultipartReader()
+	if err != nil {
+		return err
+	}
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		pw.CloseWithError(copyParts(reader, writer, maxFileSize))
+	}()
+
+	form, err := multipart.NewReader(pr, writer.Boundary()).ReadForm(maxMemory)
+	// Stops the copy when ReadForm gave up early
+	pr.Close()
+	<-copied
+	if err != nil {
+		return err
+	}
+
+	r := nr.Request
+	r.MultipartForm = form
+	r.PostForm = form.Value
+	if r.Form == nil {
+		r.Form = r.URL.Query()
+	}
+	for key, values := range form.Value {
+		r.Form[key] = append(r.Form[key], values...)
+	}
+	return nil
+}
+
+// copyParts writes the parts of reader to writer, file parts failing with
+// ErrFileTooLarge past maxFileSize
+func copyParts(reader *multipart.Reader, writer *multipart.Writer, maxFileSize int64) error {
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return writer.Close()
+		}
+		if err != nil {
+			return err
+		}
+		dst, err := writer.CreatePart(part.Header)
+		if err != nil {
+			return err
+		}
+		var src io.Reader = part
+		if part.FileName() != "" {
+			src = &limitedReader{r: part, remaining: maxFileSize}
+		}
+		if _, err := io.Copy(dst, src); err != nil {
+			// Closing the part would read the rest of the file
+			return err
+		}
+		part.Close()
+	}
+}
+
+// checkMultipartForm applies the size and type limits of opts to a parsed form
+func checkMultipartForm(form *multipart.Form, opts MultipartOptions) error {
+	var total int64
+	for _, values := range form.Value {
+		for _, value := range values {
+			total += int64(len(value))
+		}
+	}
+	for _, files := range form.File {
+		for _, fh := range files {
+			total += fh.Size
+			if opts.MaxFileSize > 0 && fh.Size > opts.MaxFileSize {
+				return multipartError(fmt.Errorf("%w: %s", ErrFileTooLarge, fh.Filename))
+			}
+			if len(opts.AllowedTypes) > 0 {
+				contentType, err := fileHeaderType(fh)
+				if err != nil {
+					return multipartError(err)
+				}
+				if !mediaTypeAllowed(contentType, opts.AllowedTypes) {
+					return multipartError(fmt.Errorf("%w: %s is %s", ErrMediaTypeNotAllowed, fh.Filename, contentType))
+				}
+			}
+		}
+	}
+	if opts.MaxTotalSize > 0 && total > opts.MaxTotalSize {
+		// The form was parsed earlier without the limit, the encoding
+		// overhead is not counted
+		return multipartError(ErrBodyTooLarge)
+	}
+	return nil
+}
+
+// FormFile returns the first file uploaded under name, parsing the body with
+// the Multipart options of the router when it was not parsed yet.
+func (nr *NinaRequest) FormFile(name string) (*multipart.FileHeader, error) {
+	files, err := nr.Files(name)
+	if err != nil {
+		return nil, err
+	}
+	return files[0], nil
+}
+
+// Files returns every file uploaded under name, parsing the body with the
+// Multipart options of the router when it was not parsed yet.
+func (nr *NinaRequest) Files(name string) ([]*multipart.FileHeader, error) {
+	if err := nr.ParseMultipart(nr.multipartOptions()); err != nil {
+		return nil, err
+	}
+	files := nr.MultipartForm.File[name]
+	if len(files) == 0 {
+		return nil, NewHTTPError(http.StatusBadRequest, "missing_file", fmt.Sprintf("No file uploaded as %q", name)).Wrap(http.ErrMissingFile)
+	}
+	return files, nil
+}
+
+// multipartOptions are the default upload limits of the router of the request
+func (nr *NinaRequest) multipartOptions() MultipartOptions {
+	if nr.router == nil {
+		return MultipartOptions{}
+	}
+	return nr.router.Multipart
+}
+
+// EachPart streams the multipart body calling fn for every file part, without
+// buffering the files in memory or on disk. Plain fields are collected into
+// Params.FormAll. Returning an error from fn stops the iteration.
+func (nr *NinaRequest) EachPart(opts MultipartOptions, fn func(part *FilePart) error) error {
+	if nr.router.bodyKind(nr.Header.Get("Content-Type")) != bodyMultipart {
+		return NewHTTPError(http.StatusUnsupportedMediaType, "not_multipart", "").Wrap(ErrNotMultipart)
+	}
+	if opts.MaxTotalSize > 0 {
+		nr.Request.Body = http.MaxBytesReader(nil, nr.Request.Body, opts.MaxTotalSize)
+	}
+	reader, err := nr.Request.MultipartReader()
+	if err != nil {
+		return invalidMultipart(err)
+	}
+
+	maxMemory := opts.MaxMemory
+	if maxMemory <= 0 {
+		maxMemory = DefaultMultipartMemory
+	}
+	values := make(map[string][]string)
+	if nr.Params != nil {
+		nr.Params.form = values
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return invalidMultipart(err)
+		}
+
+		if part.FileName() == "" {
+			// Plain field, keep it like the form parser would
+			value, err := io.ReadAll(io.LimitReader(part, maxMemory+1))
+			part.Close()
+			if err != nil {
+				return invalidMultipart(err)
+			}
+			if int64(len(value)) > maxMemory {
+				return multipartError(ErrBodyTooLarge)
+			}
+			values[part.FormName()] = append(values[part.FormName()], string(value))
+			continue
+		}
+
+		filePart, err := newFilePart(part, opts)
+		if err == nil {
+			err = fn(filePart)
+		}
+		if err != nil {
+			// Closing the part would read the rest of the file
+			return multipartError(err)
+		}
+		part.Close()
+	}
+}
+
+// SaveFile stores an uploaded file at path.
+func SaveFile(fh *multipart.FileHeader, path string) (int64, error) {
+	src, err := fh.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	return saveReader(src, path)
+}
+
+// CopyFile writes an uploaded file to w.
+func CopyFile(fh *multipart.FileHeader, w io.Writer) (int64, error) {
+	src, err := fh.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	return io.Copy(w, src)
+}
+
+func saveReader(src io.Reader, path string) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+	dst, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// Do not leave half written uploads around
+		os.Remove(path)
+	}
+	return n, err
+}
+
+func newFilePart(part *multipart.Part, opts MultipartOptions) (*FilePart, error) {
+	buffered := bufio.NewReaderSize(part, 512)
+	contentType := declaredType(part.Header.Get("Content-Type"))
+	if contentType == "" || contentType == "application/octet-stream" {
+		// Sniff the first bytes when the client did not say what it sends
+		head, err := buffered.Peek(512)
+		if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, err
+		}
+		contentType = declaredType(http.DetectContentType(head))
+	}
+	if len(opts.AllowedTypes) > 0 && !mediaTypeAllowed(contentType, opts.AllowedTypes) {
+		return nil, fmt.Errorf("%w: %s is %s", ErrMediaTypeNotAllowed, part.FileName(), contentType)
+	}
+
+	var reader io.Reader = buffered
+	if opts.MaxFileSize > 0 {
+		reader = &limitedReader{r: buffered, remaining: opts.MaxFileSize}
+	}
+	return &FilePart{Part: part, ContentType: contentType, reader: reader}, nil
+}
+
+// limitedReader fails instead of silently truncating like io.LimitReader
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedReader) Read(b []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrFileTooLarge
+	}
+	if int64(len(b)) > l.remaining+1 {
+		b = b[:l.remaining+1]
+	}
+	n, err := l.r.Read(b)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), ErrFileTooLarge
+	}
+	return n, err
+}
+
+func fileHeaderType(fh *multipart.FileHeader) (string, error) {
+	contentType := declaredType(fh.Header.Get("Content-Type"))
+	if contentType != "" && contentType != "application/octet-stream" {
+		return contentType, nil
+	}
+	src, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	return declaredType(http.DetectContentType(head[:n])), nil
+}
+
+// declaredType strips the parameters of a Content-Type value
+func declaredType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mediaType
+}
+
+func mediaTypeAllowed(contentType string, allowed []string) bool {
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(pattern)
+		if pattern == contentType || pattern == "*/*" {
+			return true
+		}
+		if strings.HasSuffix(pattern, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// multipartError maps the upload limits to the matching HTTP errors, other
+// errors, like the ones returned by EachPart callbacks, are returned as is
+func multipartError(err error) error {
+	var httpErr *HTTPError
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &httpErr):
+		return err
+	case errors.As(err, &maxBytesErr), errors.Is(err, ErrBodyTooLarge), errors.Is(err, multipart.ErrMessageTooLarge):
+		return NewHTTPError(http.StatusRequestEntityTooLarge, "body_too_large", "").Wrap(err)
+	case errors.Is(err, ErrFileTooLarge):
+		return NewHTTPError(http.StatusRequestEntityTooLarge, "file_too_large", "").Wrap(err)
+	case errors.Is(err, ErrMediaTypeNotAllowed):
+		return NewHTTPError(http.StatusUnsupportedMediaType, "media_type_not_allowed", "").Wrap(err)
+	default:
+		return err
+	}
+}
+
+// invalidMultipart is multipartError for failures of the multipart parser
+// itself, anything that is not a known limit is a malformed body
+func invalidMultipart(err error) error {
+	if mapped := multipartError(err); mapped != err {
+		return mapped
+	}
+	return NewHTTPError(http.StatusBadRequest, "invalid_multipart", "Unable to parse multipart form").Wrap(err)
+}
@@ -0,0 +1,335 @@
+package router
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type uploadFile struct {
+	field       string
+	name        string
+	contentType string
+	content     string
+}
+
+func newMultipartRequest(t *testing.T, fields map[string]string, files []uploadFile) *http.Request {
+	t.Helper()
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for key, value := range fields {
+		if err := mw.WriteField(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range files {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, f.field, f.name))
+		if f.contentType != "" {
+			header.Set("Content-Type", f.contentType)
+		}
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(part, f.content)
+	}
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestFormFileAndFiles(t *testing.T) {
+	nr := NewRouter()
+	nr.POST("/upload", E(func(w http.ResponseWriter, r *NinaRequest) error {
+		avatar, err := r.FormFile("avatar")
+		if err != nil {
+			return err
+		}
+		docs, err := r.Files("docs")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s|%d|%s:", avatar.Filename, len(docs), r.Params.FormAll("title"))
+		for _, doc := range docs {
+			CopyFile(doc, w)
+		}
+		return nil
+	}), nil)
+
+	req := newMultipartRequest(t, map[string]string{"title": "hello"}, []uploadFile{
+		{"avatar", "me.png", "image/png", "png"},
+		{"docs", "a.txt", "text/plain", "A"},
+		{"docs", "b.txt", "text/plain", "B"},
+	})
+	rr := httptest.NewRecorder()
+	nr.ServeHTTP(rr, req)
+
+	if want := "me.png|2|[hello]:AB"; rr.Body.String() != want {
+		t.Errorf("got %v, want %v", rr.Body.String(), want)
+	}
+}
+
+func TestParseMultipartLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       MultipartOptions
+		files      []uploadFile
+		wantStatus int
+		wantCode   string
+	}{
+		{"Within limits", MultipartOptions{MaxFileSize: 10, AllowedTypes: []string{"image/*"}}, []uploadFile{{"f", "a.png", "image/png", "small"}}, http.StatusOK, ""},
+		{"File too large", MultipartOptions{MaxFileSize: 3}, []uploadFile{{"f", "a.txt", "text/plain", "too big"}}, http.StatusRequestEntityTooLarge, "file_too_large"},
+		{"Body too large", MultipartOptions{MaxTotalSize: 64}, []uploadFile{{"f", "a.txt", "text/plain", strings.Repeat("x", 256)}}, http.StatusRequestEntityTooLarge, "body_too_large"},
+		{"Type not allowed", MultipartOptions{AllowedTypes: []string{"image/png"}}, []uploadFile{{"f", "a.txt", "text/plain", "text"}}, http.StatusUnsupportedMediaType, "media_type_not_allowed"},
+		{"Sniffed type", MultipartOptions{AllowedTypes: []string{"image/png"}}, []uploadFile{{"f", "a.bin", "application/octet-stream", "<html><body>hi</body></html>"}}, http.StatusUnsupportedMediaType, "media_type_not_allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nr := NewRouter()
+			nr.POST("/upload", E(func(w http.ResponseWriter, r *NinaRequest) error {
+				return r.ParseMultipart(tt.opts)
+			}), nil)
+
+			rr := httptest.NewRecorder()
+			nr.ServeHTTP(rr, newMultipartRequest(t, nil, tt.files))
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %v, want %v", rr.Code, tt.wantStatus)
+			}
+			if tt.wantCode != "" && !strings.Contains(rr.Body.String(), `"code":"`+tt.wantCode+`"`) {
+				t.Errorf("got body %v, want code %v", rr.Body.String(), tt.wantCode)
+			}
+		})
+	}
+}
+
+// countingReader counts the bytes of the body read by the parser
+type countingReader struct {
+	r    io.Reader
+	read int
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.read += n
+	return n, err
+}
+
+func TestMultipartLimitsAcrossCalls(t *testing.T) {
+	tests := []struct {
+		name       string
+		defaults   MultipartOptions
+		handler    func(r *NinaRequest) error
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name: "Checked again after Bind",
+			handler: func(r *NinaRequest) error {
+				var dst struct {
+					Title string `form:"title"`
+				}
+				if err := r.Bind(&dst); err != nil {
+					return err
+				}
+				return r.ParseMultipart(MultipartOptions{MaxFileSize: 4, AllowedTypes: []string{"image/png"}})
+			},
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantCode:   "file_too_large",
+		},
+		{
+			name: "Type checked again",
+			handler: func(r *NinaRequest) error {
+				if err := r.ParseMultipart(MultipartOptions{}); err != nil {
+					return err
+				}
+				return r.ParseMultipart(MultipartOptions{AllowedTypes: []string{"image/png"}})
+			},
+			wantStatus: http.StatusUnsupportedMediaType,
+			wantCode:   "media_type_not_allowed",
+		},
+		{
+			name:     "Router defaults for FormFile",
+			defaults: MultipartOptions{MaxFileSize: 4},
+			handler: func(r *NinaRequest) error {
+				_, err := r.FormFile("f")
+				return err
+			},
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantCode:   "file_too_large",
+		},
+		{
+			name:     "Within router defaults",
+			defaults: MultipartOptions{MaxFileSize: 64, AllowedTypes: []string{"text/*"}},
+			handler: func(r *NinaRequest) error {
+				_, err := r.Files("f")
+				return err
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nr := NewRouter()
+			nr.Multipart = tt.defaults
+			nr.POST("/upload", E(func(w http.ResponseWriter, r *NinaRequest) error {
+				return tt.handler(r)
+			}), nil)
+
+			req := newMultipartRequest(t, map[string]string{"title": "hello"}, []uploadFile{{"f", "a.txt", "text/plain", "twenty four bytes of txt"}})
+			rr := httptest.NewRecorder()
+			nr.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %v, want %v: %v", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantCode != "" && !strings.Contains(rr.Body.String(), `"code":"`+tt.wantCode+`"`) {
+				t.Errorf("got body %v, want code %v", rr.Body.String(), tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestParseMultipartStopsLargeFiles(t *testing.T) {
+	nr := NewRouter()
+	nr.POST("/upload", E(func(w http.ResponseWriter, r *NinaRequest) error {
+		return r.ParseMultipart(MultipartOptions{MaxFileSize: 4})
+	}), nil)
+
+	req := newMultipartRequest(t, nil, []uploadFile{{"f", "big.bin", "application/octet-stream", strings.Repeat("x", 1<<20)}})
+	body := &countingReader{r: req.Body}
+	req.Body = io.NopCloser(body)
+	rr := httptest.NewRecorder()
+	nr.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("got status %v, want %v", rr.Code, http.StatusRequestEntityTooLarge)
+	}
+	if body.read >= 1<<20 {
+		t.Errorf("read %d bytes, the upload should be refused while streaming", body.read)
+	}
+}
+
+func TestEachPart(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name       string
+		opts       MultipartOptions
+		content    string
+		wantStatus int
+		wantBody   string
+	}{
+		{"Streamed to disk", MultipartOptions{MaxFileSize: 16}, "streamed", http.StatusOK, "notes.txt text/plain 8 [nina]"},
+		{"File too large", MultipartOptions{MaxFileSize: 4}, "streamed", http.StatusRequestEntityTooLarge, ""},
+		{"Type not allowed", MultipartOptions{AllowedTypes: []string{"image/*"}}, "streamed", http.StatusUnsupportedMediaType, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nr := NewRouter()
+			nr.POST("/upload", E(func(w http.ResponseWriter, r *NinaRequest) error {
+				var saved []string
+				err := r.EachPart(tt.opts, func(part *FilePart) error {
+					n, err := part.SaveTo(filepath.Join(dir, part.FileName()))
+					saved = append(saved, fmt.Sprintf("%s %s %d", part.FileName(), part.ContentType, n))
+					return err
+				})
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(w, "%s %v", strings.Join(saved, ","), r.Params.FormAll("owner"))
+				return nil
+			}), nil)
+
+			req := newMultipartRequest(t, map[string]string{"owner": "nina"}, []uploadFile{{"file", "notes.txt", "text/plain; charset=utf-8", tt.content}})
+			rr := httptest.NewRecorder()
+			nr.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %v, want %v: %v", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantBody != "" && rr.Body.String() != tt.wantBody {
+				t.Errorf("got body %v, want %v", rr.Body.String(), tt.wantBody)
+			}
+
+			saved, err := os.ReadFile(filepath.Join(dir, "notes.txt"))
+			if tt.wantStatus == http.StatusOK && string(saved) != tt.content {
+				t.Errorf("got saved file %q, want %q", saved, tt.content)
+			}
+			if tt.wantStatus == http.StatusRequestEntityTooLarge && err == nil {
+				t.Error("a partial upload should not be left on disk")
+			}
+			os.Remove(filepath.Join(dir, "notes.txt"))
+		})
+	}
+}
+
+func TestMultipartErrors(t *testing.T) {
+	nr := NewRouter()
+	nr.POST("/upload", E(func(w http.ResponseWriter, r *NinaRequest) error {
+		_, err := r.FormFile("missing")
+		return err
+	}), nil)
+
+	tests := []struct {
+		name       string
+		req        *http.Request
+		wantStatus int
+		wantCode   string
+	}{
+		{"Missing file", newMultipartRequest(t, map[string]string{"a": "b"}, nil), http.StatusBadRequest, "missing_file"},
+		{"Not multipart", httptest.NewRequest(http.MethodPost, "/upload", nil), http.StatusUnsupportedMediaType, "not_multipart"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			nr.ServeHTTP(rr, tt.req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %v, want %v", rr.Code, tt.wantStatus)
+			}
+			if !strings.Contains(rr.Body.String(), `"code":"`+tt.wantCode+`"`) {
+				t.Errorf("got body %v, want code %v", rr.Body.String(), tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestBindMultipartForm(t *testing.T) {
+	type profile struct {
+		Name string   `form:"name"`
+		Tags []string `form:"tags"`
+	}
+
+	nr := NewRouter()
+	nr.POST("/upload", E(func(w http.ResponseWriter, r *NinaRequest) error {
+		var p profile
+		if err := r.Bind(&p); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s %v", p.Name, p.Tags)
+		return nil
+	}), nil)
+
+	rr := httptest.NewRecorder()
+	nr.ServeHTTP(rr, newMultipartRequest(t, map[string]string{"name": "nina", "tags[]": "go"}, nil))
+
+	if want := "nina [go]"; rr.Body.String() != want {
+		t.Errorf("got %v, want %v", rr.Body.String(), want)
+	}
+}
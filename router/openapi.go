@@ -0,0 +1,405 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// OpenAPIVersion is the version of the documents built by OpenAPI.
+const OpenAPIVersion = "3.1.0"
+
+// OpenAPIDocument is the subset of an OpenAPI 3.1 document built from the
+// route table. It also reads hand written documents, see the middleware
+// package for request validation against one.
+type OpenAPIDocument struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       OpenAPIInfo          `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components *Components          `json:"components,omitempty"`
+}
+
+// OpenAPIInfo is the info object of the document.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of a path by lower case method.
+type PathItem map[string]*Operation
+
+// UnmarshalJSON keeps the operations of a path item, the parameters shared by
+// the path are copied to the operations that do not override them.
+func (item *PathItem) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	var shared []*Parameter
+	if params, ok := raw["parameters"]; ok {
+		if err := json.Unmarshal(params, &shared); err != nil {
+			return err
+		}
+	}
+
+	*item = make(PathItem)
+	for key, value := range raw {
+		if !openAPIMethods[strings.ToUpper(key)] {
+			continue
+		}
+		op := &Operation{}
+		if err := json.Unmarshal(value, op); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		for _, param := range shared {
+			if op.parameter(param.Name, param.In) == nil {
+				op.Parameters = append(op.Parameters, param)
+			}
+		}
+		(*item)[strings.ToLower(key)] = op
+	}
+	return nil
+}
+
+// Operation documents one method of a path.
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+// parameter returns the parameter name in in, nil when there is none
+func (op *Operation) parameter(name, in string) *Parameter {
+	for _, param := range op.Parameters {
+		if param.Name == name && param.In == in {
+			return param
+		}
+	}
+	return nil
+}
+
+// Parameter is a path, query or header parameter of an operation.
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema,omitempty"`
+}
+
+// RequestBody is the body accepted by an operation, by media type.
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response is a response of an operation, by media type.
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body.
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Components holds the schemas referenced by the operations and the security
+// schemes.
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how the API is authenticated, see
+// ServeMux.SecurityScheme.
+type SecurityScheme struct {
+	Type         string `json:"type"` // apiKey, http, mutualTLS, oauth2 or openIdConnect
+	Description  string `json:"description,omitempty"`
+	Name         string `json:"name,omitempty"` // apiKey
+	In           string `json:"in,omitempty"`   // apiKey: query, header or cookie
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// LoadOpenAPI reads an OpenAPI 3 document written in JSON from path. YAML
+// documents are not supported, convert them to JSON first.
+func LoadOpenAPI(path string) (*OpenAPIDocument, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return nil, fmt.Errorf("openapi %s: only JSON specs are supported, convert the YAML document to JSON", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc := &OpenAPIDocument{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("openapi %s: %w", path, err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("openapi %s: unsupported version %q", path, doc.OpenAPI)
+	}
+	return doc, nil
+}
+
+// routeDoc is the metadata attached to a route for the OpenAPI document
+type routeDoc struct {
+	summary     string
+	description string
+	tags        []string
+	request     reflect.Type
+	responses   map[int]reflect.Type
+	security    []string
+	deprecated  bool
+}
+
+// Summary sets the short summary of the route in the OpenAPI document.
+func (rt *Route) Summary(summary string) *Route {
+	rt.doc.summary = summary
+	return rt
+}
+
+// Description sets the long description of the route.
+func (rt *Route) Description(description string) *Route {
+	rt.doc.description = description
+	return rt
+}
+
+// Tags groups the route under tags in the OpenAPI document.
+func (rt *Route) Tags(tags ...string) *Route {
+	rt.doc.tags = append(rt.doc.tags, tags...)
+	return rt
+}
+
+// Request documents the struct the handler binds, v is a value or a pointer
+// of that type. Fields tagged uri, query and header become parameters, the
+// json fields the request body of POST, PUT and PATCH routes, and the
+// validate rules their constraints.
+func (rt *Route) Request(v interface{}) *Route {
+	rt.doc.request = reflect.TypeOf(v)
+	return rt
+}
+
+// Response documents a response of the route, v is nil for responses
+// without body.
+func (rt *Route) Response(status int, v interface{}) *Route {
+	if rt.doc.responses == nil {
+		rt.doc.responses = make(map[int]reflect.Type)
+	}
+	rt.doc.responses[status] = reflect.TypeOf(v)
+	return rt
+}
+
+// Security lists the security schemes protecting the route, any of them is
+// enough. The schemes are declared with ServeMux.SecurityScheme.
+func (rt *Route) Security(schemes ...string) *Route {
+	rt.doc.security = append(rt.doc.security, schemes...)
+	return rt
+}
+
+// Deprecated marks the route as deprecated in the OpenAPI document.
+func (rt *Route) Deprecated() *Route {
+	rt.doc.deprecated = true
+	return rt
+}
+
+// SecurityScheme declares a security scheme usable by Route.Security.
+func (mux *ServeMux) SecurityScheme(name string, scheme SecurityScheme) {
+	if mux.securitySchemes == nil {
+		mux.securitySchemes = make(map[string]*SecurityScheme)
+	}
+	mux.securitySchemes[name] = &scheme
+}
+
+// openAPIMethods are the methods an OpenAPI path item can hold, routes for
+// any method or for other methods are left out of the document
+var openAPIMethods = map[string]bool{
+	http.MethodGet: true, http.MethodPut: true, http.MethodPost: true, http.MethodDelete: true,
+	http.MethodOptions: true, http.MethodHead: true, http.MethodPatch: true, http.MethodTrace: true,
+}
+
+// OpenAPI builds an OpenAPI 3.1 document from the route table, mounted
+// routers included. Every operation documents the problem details returned
+// on errors as its default response.
+func (mux *ServeMux) OpenAPI() *OpenAPIDocument {
+	info := mux.OpenAPIInfo
+	if info.Title == "" {
+		info.Title = "API"
+	}
+	if info.Version == "" {
+		info.Version = "0.0.0"
+	}
+
+	doc := &OpenAPIDocument{
+		OpenAPI: OpenAPIVersion,
+		Info:    info,
+		Paths:   make(map[string]*PathItem),
+	}
+	schemas := newSchemaRegistry()
+	securitySchemes := make(map[string]*SecurityScheme)
+	mux.addOperations(doc, "", schemas, securitySchemes)
+
+	components := &Components{SecuritySchemes: securitySchemes}
+	if len(securitySchemes) == 0 {
+		components.SecuritySchemes = nil
+	}
+	components.Schemas = schemas.schemas
+	components.Schemas["Problem"] = problemSchema()
+	doc.Components = components
+	return doc
+}
+
+func (mux *ServeMux) addOperations(doc *OpenAPIDocument, prefix string, schemas *schemaRegistry, securitySchemes map[string]*SecurityScheme) {
+	for name, scheme := range mux.securitySchemes {
+		securitySchemes[name] = scheme
+	}
+	for _, route := range mux.routeList {
+		path := openAPIPath(route)
+		if path == "" {
+			continue
+		}
+		path = prefix + path
+		for _, method := range route.methods {
+			if !openAPIMethods[method] {
+				continue
+			}
+			item := doc.Paths[path]
+			if item == nil {
+				item = &PathItem{}
+				doc.Paths[path] = item
+			}
+			(*item)[strings.ToLower(method)] = route.operation(method, schemas)
+		}
+	}
+	for _, mount := range mux.mounts {
+		mount.sub.addOperations(doc, prefix+mount.prefix, schemas, securitySchemes)
+	}
+}
+
+// openAPIPath turns /users/{id:int}/{path...} into /users/{id}/{path}, host
+// patterns are left out as OpenAPI paths cannot hold the host
+func openAPIPath(route *Route) string {
+	if !strings.HasPrefix(route.pattern, "/") {
+		return ""
+	}
+	var b strings.Builder
+	i := 0
+	eachPatternParam(route.pattern, func(literal, inner string) {
+		b.WriteString(literal)
+		if inner == "" || inner == "$" {
+			return
+		}
+		b.WriteString("{" + route.rp.params[i].name + "}")
+		i++
+	})
+	return b.String()
+}
+
+// operationID is the route name, suffixed with the method when the route
+// serves several of them as operation ids must be unique
+func (rt *Route) operationID(method string) string {
+	if rt.name == "" {
+		return ""
+	}
+	count := 0
+	for _, m := range rt.methods {
+		if openAPIMethods[m] {
+			count++
+		}
+	}
+	if count > 1 {
+		return rt.name + "." + strings.ToLower(method)
+	}
+	return rt.name
+}
+
+func (rt *Route) operation(method string, schemas *schemaRegistry) *Operation {
+	op := &Operation{
+		OperationID: rt.operationID(method),
+		Summary:     rt.doc.summary,
+		Description: rt.doc.description,
+		Tags:        rt.doc.tags,
+		Deprecated:  rt.doc.deprecated,
+		Responses:   make(map[string]*Response),
+	}
+	if len(op.Tags) == 0 {
+		op.Tags = nil
+	}
+
+	// Path params, typed by their constraint or by the bound field
+	var fields map[string]map[string]reflect.StructField
+	if rt.doc.request != nil {
+		fields = paramFields(rt.doc.request)
+	}
+	for _, param := range rt.rp.params {
+		schema := constraintSchema(param.constraint)
+		if field, ok := fields[tagURI][param.name]; ok && param.constraint == "" {
+			schema = schemas.fieldSchema(field)
+		}
+		op.Parameters = append(op.Parameters, &Parameter{Name: param.name, In: "path", Required: true, Schema: schema})
+	}
+	for _, in := range []string{tagQuery, tagHeader} {
+		for _, name := range sortedKeys(fields[in]) {
+			field := fields[in][name]
+			op.Parameters = append(op.Parameters, &Parameter{
+				Name:     name,
+				In:       in,
+				Required: hasRule(field, "required"),
+				Schema:   schemas.fieldSchema(field),
+			})
+		}
+	}
+
+	if rt.doc.request != nil && (method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch) {
+		if body := schemas.bodySchema(rt.doc.request); body != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]*MediaType{"application/json": {Schema: body}},
+			}
+		}
+	}
+
+	for status, t := range rt.doc.responses {
+		response := &Response{Description: http.StatusText(status)}
+		if t != nil {
+			response.Content = map[string]*MediaType{"application/json": {Schema: schemas.schema(t)}}
+		}
+		op.Responses[strconv.Itoa(status)] = response
+	}
+	if len(rt.doc.responses) == 0 {
+		op.Responses["200"] = &Response{Description: http.StatusText(http.StatusOK)}
+	}
+	op.Responses["default"] = &Response{
+		Description: "Error",
+		Content:     map[string]*MediaType{ProblemContentType: {Schema: &Schema{Ref: "#/components/schemas/Problem"}}},
+	}
+
+	for _, scheme := range rt.doc.security {
+		op.Security = append(op.Security, map[string][]string{scheme: {}})
+	}
+	return op
+}
+
+// OpenAPIHandler serves the OpenAPI document of the router as JSON, register
+// it on the path of your choice:
+//
+//	mux.GET("/openapi.json", mux.OpenAPIHandler(), nil)
+func (mux *ServeMux) OpenAPIHandler() Handler {
+	return func(w http.ResponseWriter, r *NinaRequest) {
+		if err := r.Response(w).JSON(http.StatusOK, mux.OpenAPI()); err != nil {
+			r.Error(w, err)
+		}
+	}
+}
@@ -0,0 +1,462 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema is the subset of JSON Schema used by the OpenAPI documents.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Not                  *Schema            `json:"not,omitempty"`
+	// Nullable also accepts null, set by OpenAPI 3.0 documents and by a
+	// 3.1 type array holding "null"
+	Nullable bool `json:"nullable,omitempty"`
+}
+
+// UnmarshalJSON reads the boolean schemas and the 3.1 type arrays of hand
+// written documents: true accepts anything, false is read as {"not": {}} and
+// ["string", "null"] as a nullable string. The 3.0 boolean exclusiveMinimum
+// and exclusiveMaximum are turned into their 3.1 numeric form.
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	switch string(bytes.TrimSpace(data)) {
+	case "true":
+		*s = Schema{}
+		return nil
+	case "false":
+		*s = Schema{Not: &Schema{}}
+		return nil
+	}
+
+	type plain Schema
+	var raw struct {
+		plain
+		Type             json.RawMessage `json:"type,omitempty"`
+		ExclusiveMinimum json.RawMessage `json:"exclusiveMinimum,omitempty"`
+		ExclusiveMaximum json.RawMessage `json:"exclusiveMaximum,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*s = Schema(raw.plain)
+	var err error
+	if s.ExclusiveMinimum, s.Minimum, err = exclusiveBound(raw.ExclusiveMinimum, s.Minimum); err != nil {
+		return err
+	}
+	if s.ExclusiveMaximum, s.Maximum, err = exclusiveBound(raw.ExclusiveMaximum, s.Maximum); err != nil {
+		return err
+	}
+	if len(raw.Type) == 0 {
+		return nil
+	}
+	if raw.Type[0] != '[' {
+		return json.Unmarshal(raw.Type, &s.Type)
+	}
+	var types []string
+	if err := json.Unmarshal(raw.Type, &types); err != nil {
+		return err
+	}
+	for _, t := range types {
+		if t == "null" {
+			s.Nullable = true
+		} else if s.Type == "" {
+			s.Type = t
+		}
+	}
+	return nil
+}
+
+// exclusiveBound reads an exclusive bound written as a number, or as a
+// boolean making the inclusive bound exclusive
+func exclusiveBound(raw json.RawMessage, inclusive *float64) (exclusive, bound *float64, err error) {
+	switch string(raw) {
+	case "", "false":
+		return nil, inclusive, nil
+	case "true":
+		return inclusive, nil, nil
+	}
+	err = json.Unmarshal(raw, &exclusive)
+	return exclusive, inclusive, err
+}
+
+// schemaRegistry builds schemas from Go types, named structs are stored once
+// in the components and referenced
+type schemaRegistry struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{schemas: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+}
+
+var (
+	// pkgPathRegex matches the import path before the package name of the
+	// type arguments of generic types
+	pkgPathRegex       = regexp.MustCompile(`[^\[\],*\s]*/`)
+	componentNameRegex = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+)
+
+// componentName names the component schema of t after its package and type,
+// like router.User or router.Page_router.User for generics. Types sharing a
+// name get a numbered suffix.
+func (reg *schemaRegistry) componentName(t reflect.Type) string {
+	if name, found := reg.names[t]; found {
+		return name
+	}
+	name := pkgPathRegex.ReplaceAllString(t.Name(), "")
+	if pkg := path.Base(t.PkgPath()); pkg != "." && pkg != "/" {
+		name = pkg + "." + name
+	}
+	name = strings.Trim(componentNameRegex.ReplaceAllString(name, "_"), "_")
+
+	unique := name
+	for i := 2; reg.schemas[unique] != nil; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	reg.names[t] = unique
+	return unique
+}
+
+// schema returns the schema of t, a reference for named structs
+func (reg *schemaRegistry) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == durationType:
+		return &Schema{Type: "string", Format: "duration"}
+	case t.Kind() == reflect.Struct && reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &Schema{Type: "integer", Minimum: &zero}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: reg.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: reg.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return reg.structSchema(t, nil)
+		}
+		_, found := reg.names[t]
+		name := reg.componentName(t)
+		if !found {
+			reg.schemas[name] = &Schema{} // recursive types point to the placeholder
+			*reg.schemas[name] = *reg.structSchema(t, nil)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		// interface{} accepts anything
+		return &Schema{}
+	}
+}
+
+// fieldSchema is the schema of a struct field with its validate rules applied
+func (reg *schemaRegistry) fieldSchema(field reflect.StructField) *Schema {
+	schema := reg.schema(field.Type)
+	if schema.Ref != "" {
+		return schema
+	}
+	applyRules(schema, field)
+	return schema
+}
+
+// bodySchema is the schema of the json fields of a request struct, nil when
+// it binds nothing from the body
+func (reg *schemaRegistry) bodySchema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return reg.schema(t)
+	}
+	schema := reg.structSchema(t, func(field reflect.StructField) bool {
+		// Fields only bound from the URI, query or headers are not in the body
+		_, hasJSON := field.Tag.Lookup("json")
+		return hasJSON || !hasAnyTag(field, tagURI, tagQuery, tagHeader)
+	})
+	if len(schema.Properties) == 0 {
+		return nil
+	}
+	return schema
+}
+
+// structSchema describes the fields of t like encoding/json sees them, keep
+// filters the fields when set
+func (reg *schemaRegistry) structSchema(t reflect.Type, keep func(reflect.StructField) bool) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || (keep != nil && !keep(field)) {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			// Embedded structs are flattened like encoding/json does
+			embedded := reg.structSchema(fieldType, keep)
+			for key, value := range embedded.Properties {
+				schema.Properties[key] = value
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = reg.fieldSchema(field)
+		if hasRule(field, "required") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// applyRules maps the validate rules of field to schema keywords
+func applyRules(schema *Schema, field reflect.StructField) {
+	for _, rule := range splitRules(field.Tag.Get("validate")) {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "min", "max", "len", "gt", "gte", "lt", "lte":
+			applyBound(schema, name, param)
+		case "oneof":
+			for _, option := range strings.Fields(param) {
+				schema.Enum = append(schema.Enum, enumValue(schema.Type, option))
+			}
+		case "email":
+			schema.Format = "email"
+		case "url":
+			schema.Format = "uri"
+		case "uuid":
+			schema.Format = "uuid"
+		case "alpha":
+			schema.Pattern = alphaRegex.String()
+		case "alphanum":
+			schema.Pattern = alphanumRegex.String()
+		case "regex":
+			schema.Pattern = param
+		}
+	}
+}
+
+func applyBound(schema *Schema, rule, param string) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		// Durations and other non numeric bounds have no JSON Schema keyword
+		return
+	}
+	switch schema.Type {
+	case "string", "array":
+		size := int(n)
+		lower, upper := &schema.MinLength, &schema.MaxLength
+		if schema.Type == "array" {
+			lower, upper = &schema.MinItems, &schema.MaxItems
+		}
+		switch rule {
+		case "min", "gte":
+			*lower = &size
+		case "max", "lte":
+			*upper = &size
+		case "len":
+			*lower, *upper = &size, &size
+		}
+	case "integer", "number":
+		switch rule {
+		case "min", "gte":
+			schema.Minimum = &n
+		case "max", "lte":
+			schema.Maximum = &n
+		case "gt":
+			schema.ExclusiveMinimum = &n
+		case "lt":
+			schema.ExclusiveMaximum = &n
+		}
+	}
+}
+
+func enumValue(schemaType, option string) interface{} {
+	switch schemaType {
+	case "integer", "number":
+		if n, err := strconv.ParseFloat(option, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(option); err == nil {
+			return b
+		}
+	}
+	return option
+}
+
+// constraintSchema is the schema of a path param constraint
+func constraintSchema(constraint string) *Schema {
+	zero := 0.0
+	switch constraint {
+	case "":
+		return &Schema{Type: "string"}
+	case "int":
+		return &Schema{Type: "integer", Format: "int64"}
+	case "uint":
+		return &Schema{Type: "integer", Minimum: &zero}
+	case "float":
+		return &Schema{Type: "number"}
+	case "bool":
+		return &Schema{Type: "boolean"}
+	case "uuid":
+		return &Schema{Type: "string", Format: "uuid"}
+	case "alpha":
+		return &Schema{Type: "string", Pattern: alphaRegex.String()}
+	case "alphanum":
+		return &Schema{Type: "string", Pattern: alphanumRegex.String()}
+	default:
+		return &Schema{Type: "string", Pattern: "^(?:" + constraint + ")$"}
+	}
+}
+
+// problemSchema describes the RFC 9457 documents written on errors
+func problemSchema() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"type":     {Type: "string", Format: "uri-reference"},
+			"title":    {Type: "string"},
+			"status":   {Type: "integer"},
+			"detail":   {Type: "string"},
+			"instance": {Type: "string", Format: "uri-reference"},
+			"code":     {Type: "string"},
+		},
+	}
+}
+
+// paramFields indexes the fields of a request struct bound from the URI, the
+// query and the headers by tag then name, nested structs included
+func paramFields(t reflect.Type) map[string]map[string]reflect.StructField {
+	fields := map[string]map[string]reflect.StructField{
+		tagURI:    {},
+		tagQuery:  {},
+		tagHeader: {},
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return fields
+	}
+	for tag := range fields {
+		collectParamFields(t, tag, "", fields[tag])
+	}
+	return fields
+}
+
+func collectParamFields(t reflect.Type, tag, prefix string, fields map[string]reflect.StructField) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, hasTag := field.Tag.Lookup(tag)
+		name, _, _ = strings.Cut(name, ",")
+		if name == "-" {
+			continue
+		}
+		// Same walk as the binders, see bindStruct
+		if isNestedStruct(field.Type) {
+			nestedType := field.Type
+			if nestedType.Kind() == reflect.Ptr {
+				nestedType = nestedType.Elem()
+			}
+			nestedPrefix := prefix
+			if hasTag && name != "" {
+				nestedPrefix = prefix + name + "."
+			}
+			collectParamFields(nestedType, tag, nestedPrefix, fields)
+			continue
+		}
+		if hasTag && name != "" {
+			fields[prefix+name] = field
+		}
+	}
+}
+
+func hasRule(field reflect.StructField, rule string) bool {
+	for _, r := range splitRules(field.Tag.Get("validate")) {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyTag(field reflect.StructField, tags ...string) bool {
+	for _, tag := range tags {
+		if _, ok := field.Tag.Lookup(tag); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]reflect.StructField) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
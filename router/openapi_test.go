@@ -0,0 +1,234 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+type apiAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type createUserRequest struct {
+	Org     string     `uri:"org"`
+	Notify  bool       `query:"notify"`
+	Trace   string     `header:"X-Trace-Id" validate:"required"`
+	Name    string     `json:"name" validate:"required,min=2,max=32"`
+	Age     int        `json:"age" validate:"omitempty,gte=18"`
+	Role    string     `json:"role" validate:"omitempty,oneof=admin user"`
+	Email   string     `json:"email" validate:"omitempty,email"`
+	Address apiAddress `json:"address"`
+}
+
+type apiUser struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestOpenAPI(t *testing.T) {
+	nr := NewRouter()
+	nr.OpenAPIInfo = OpenAPIInfo{Title: "Users", Version: "1.0.0"}
+	nr.SecurityScheme("bearer", SecurityScheme{Type: "http", Scheme: "bearer"})
+	nr.POST("/orgs/{org}/users", listUsers, nil).
+		Name("user.create").
+		Summary("Create a user").
+		Tags("users").
+		Request(&createUserRequest{}).
+		Response(http.StatusCreated, apiUser{}).
+		Security("bearer")
+	nr.GET("/users/{id:int}", listUsers, nil).Response(http.StatusOK, []apiUser{})
+	nr.GET("/{$}", listUsers, nil)
+	nr.ANY("/ping", listUsers, nil)
+
+	billing := NewRouter()
+	billing.GET("/invoices/{id:uuid}", showInvoice, nil)
+	nr.Mount("/billing", billing)
+
+	doc := nr.OpenAPI()
+
+	if doc.OpenAPI != OpenAPIVersion || doc.Info.Title != "Users" {
+		t.Errorf("got header %v %+v", doc.OpenAPI, doc.Info)
+	}
+	var paths []string
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	if len(paths) != 4 || doc.Paths["/ping"] != nil || doc.Paths["/"] == nil {
+		t.Errorf("got paths %v", paths)
+	}
+
+	create := (*doc.Paths["/orgs/{org}/users"])["post"]
+	if create == nil {
+		t.Fatal("missing POST /orgs/{org}/users")
+	}
+	if create.OperationID != "user.create" || create.Summary != "Create a user" || !reflect.DeepEqual(create.Tags, []string{"users"}) {
+		t.Errorf("got operation %+v", create)
+	}
+	wantParams := []*Parameter{
+		{Name: "org", In: "path", Required: true, Schema: &Schema{Type: "string"}},
+		{Name: "notify", In: "query", Schema: &Schema{Type: "boolean"}},
+		{Name: "X-Trace-Id", In: "header", Required: true, Schema: &Schema{Type: "string"}},
+	}
+	if !reflect.DeepEqual(create.Parameters, wantParams) {
+		got, _ := json.Marshal(create.Parameters)
+		t.Errorf("got parameters %s", got)
+	}
+
+	body := create.RequestBody.Content["application/json"].Schema
+	if _, ok := body.Properties["notify"]; ok || len(body.Properties) != 5 {
+		t.Errorf("got body properties %v", body.Properties)
+	}
+	if !reflect.DeepEqual(body.Required, []string{"name"}) {
+		t.Errorf("got required %v", body.Required)
+	}
+	name := body.Properties["name"]
+	if *name.MinLength != 2 || *name.MaxLength != 32 {
+		t.Errorf("got name schema %+v", name)
+	}
+	if age := body.Properties["age"]; age.Type != "integer" || *age.Minimum != 18 {
+		t.Errorf("got age schema %+v", age)
+	}
+	if role := body.Properties["role"]; !reflect.DeepEqual(role.Enum, []interface{}{"admin", "user"}) {
+		t.Errorf("got role enum %v", role.Enum)
+	}
+	if email := body.Properties["email"]; email.Format != "email" {
+		t.Errorf("got email format %v", email.Format)
+	}
+	if address := body.Properties["address"]; address.Ref != "#/components/schemas/router.apiAddress" {
+		t.Errorf("got address %+v", address)
+	}
+
+	if created := create.Responses["201"].Content["application/json"].Schema; created.Ref != "#/components/schemas/router.apiUser" {
+		t.Errorf("got 201 schema %+v", created)
+	}
+	if create.Responses["default"].Content[ProblemContentType] == nil {
+		t.Error("missing problem details default response")
+	}
+	if !reflect.DeepEqual(create.Security, []map[string][]string{{"bearer": {}}}) {
+		t.Errorf("got security %v", create.Security)
+	}
+
+	show := (*doc.Paths["/users/{id}"])["get"]
+	if id := show.Parameters[0].Schema; id.Type != "integer" || id.Format != "int64" {
+		t.Errorf("got id schema %+v", id)
+	}
+	if list := show.Responses["200"].Content["application/json"].Schema; list.Type != "array" || list.Items.Ref == "" {
+		t.Errorf("got list schema %+v", list)
+	}
+
+	invoice := (*doc.Paths["/billing/invoices/{id}"])["get"]
+	if invoice == nil || invoice.Parameters[0].Schema.Format != "uuid" {
+		t.Errorf("got mounted operation %+v", invoice)
+	}
+
+	for _, name := range []string{"router.apiUser", "router.apiAddress", "Problem"} {
+		if doc.Components.Schemas[name] == nil {
+			t.Errorf("missing component schema %v", name)
+		}
+	}
+	if doc.Components.SecuritySchemes["bearer"].Scheme != "bearer" {
+		t.Errorf("got security schemes %v", doc.Components.SecuritySchemes)
+	}
+}
+
+func TestOpenAPIOperationIDs(t *testing.T) {
+	nr := NewRouter()
+	nr.GET("/users", listUsers, nil).Name("user.list")
+	nr.Match([]string{http.MethodPut, http.MethodPatch}, "/users/{id}", listUsers, nil).Name("user.update")
+	nr.GET("/health", listUsers, nil)
+
+	doc := nr.OpenAPI()
+
+	tests := []struct {
+		path   string
+		method string
+		want   string
+	}{
+		{"/users", "get", "user.list"},
+		{"/users/{id}", "put", "user.update.put"},
+		{"/users/{id}", "patch", "user.update.patch"},
+		{"/health", "get", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			if got := (*doc.Paths[tt.path])[tt.method].OperationID; got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type Cookie struct {
+	Flavour string `json:"flavour"`
+}
+
+type apiPage[T any] struct {
+	Items []T `json:"items"`
+}
+
+func TestOpenAPIComponentNames(t *testing.T) {
+	// Shares its name with the package level apiUser
+	type apiUser struct {
+		Login string `json:"login"`
+	}
+
+	nr := NewRouter()
+	nr.GET("/cookies", listUsers, nil).Response(http.StatusOK, Cookie{})
+	nr.GET("/http-cookies", listUsers, nil).Response(http.StatusOK, http.Cookie{})
+	nr.GET("/users", listUsers, nil).Response(http.StatusOK, apiPage[apiAddress]{})
+	nr.GET("/profiles", listUsers, nil).Response(http.StatusOK, apiPage[int]{})
+	nr.GET("/accounts", listUsers, nil).Response(http.StatusOK, apiUser{})
+	nr.GET("/members", listUsers, nil).Response(http.StatusOK, createdUser{})
+
+	doc := nr.OpenAPI()
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/cookies", "router.Cookie"},
+		{"/http-cookies", "http.Cookie"},
+		{"/users", "router.apiPage_router.apiAddress"},
+		{"/profiles", "router.apiPage_int"},
+		{"/accounts", "router.apiUser"},
+		{"/members", "router.apiUser2"},
+	}
+
+	valid := regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			schema := (*doc.Paths[tt.path])["get"].Responses["200"].Content["application/json"].Schema
+			if schema.Ref != "#/components/schemas/"+tt.want {
+				t.Errorf("got %v, want %v", schema.Ref, tt.want)
+			}
+			if !valid.MatchString(tt.want) || doc.Components.Schemas[tt.want] == nil {
+				t.Errorf("missing component schema %v", tt.want)
+			}
+		})
+	}
+}
+
+// createdUser is the package level apiUser under another name
+type createdUser = apiUser
+
+func TestOpenAPIHandler(t *testing.T) {
+	nr := NewRouter()
+	nr.GET("/users", listUsers, nil)
+	nr.GET("/docs/openapi.json", nr.OpenAPIHandler(), nil)
+
+	rr := httptest.NewRecorder()
+	nr.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/docs/openapi.json", nil))
+
+	var doc OpenAPIDocument
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid document %v: %v", rr.Body.String(), err)
+	}
+	if doc.OpenAPI != OpenAPIVersion || doc.Paths["/users"] == nil || (*doc.Paths["/users"])["get"] == nil {
+		t.Errorf("got document %v", rr.Body.String())
+	}
+}
@@ -0,0 +1,248 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ValidateValue checks a decoded JSON value against schema and returns
+// ValidationErrors naming every failure from field, nil when it is valid.
+// References are resolved against the components of the document. Numbers
+// may be float64 or json.Number, formats other than email, uri, uuid, date
+// and date-time are not checked.
+func (doc *OpenAPIDocument) ValidateValue(schema *Schema, value interface{}, field string) error {
+	var errs ValidationErrors
+	doc.validateValue(schema, value, field, &errs, 0)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Schema resolves a #/components/schemas/ reference, nil when unknown.
+func (doc *OpenAPIDocument) Schema(ref string) *Schema {
+	name, ok := strings.CutPrefix(ref, "#/components/schemas/")
+	if !ok || doc.Components == nil {
+		return nil
+	}
+	return doc.Components.Schemas[name]
+}
+
+// maxSchemaDepth stops reference cycles that never reach a value
+const maxSchemaDepth = 64
+
+func (doc *OpenAPIDocument) validateValue(schema *Schema, value interface{}, field string, errs *ValidationErrors, depth int) {
+	if schema == nil || depth > maxSchemaDepth {
+		return
+	}
+	fail := func(rule, param, message string) {
+		*errs = append(*errs, &FieldError{Field: field, Rule: rule, Param: param, Message: message})
+	}
+
+	if schema.Ref != "" {
+		doc.validateValue(doc.Schema(schema.Ref), value, field, errs, depth+1)
+		return
+	}
+	for _, sub := range schema.AllOf {
+		doc.validateValue(sub, value, field, errs, depth+1)
+	}
+	if len(schema.AnyOf) > 0 && doc.matching(schema.AnyOf, value, depth) == 0 {
+		fail("anyOf", "", "must match at least one of the schemas")
+	}
+	if len(schema.OneOf) > 0 && doc.matching(schema.OneOf, value, depth) != 1 {
+		fail("oneOf", "", "must match exactly one of the schemas")
+	}
+	if schema.Not != nil && doc.matching([]*Schema{schema.Not}, value, depth) == 1 {
+		if reflect.DeepEqual(schema.Not, &Schema{}) {
+			fail("not", "", "is not allowed")
+		} else {
+			fail("not", "", "must not match the schema")
+		}
+	}
+
+	if value == nil {
+		if schema.Type != "" && schema.Type != "null" && !schema.Nullable {
+			fail("type", schema.Type, "must not be null")
+		}
+		return
+	}
+	if schema.Type != "" && !hasJSONType(value, schema.Type) {
+		fail("type", schema.Type, "must be of type "+schema.Type)
+		return
+	}
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		options := make([]string, len(schema.Enum))
+		for i, option := range schema.Enum {
+			options[i] = fmt.Sprint(option)
+		}
+		param := strings.Join(options, " ")
+		fail("enum", param, "must be one of ["+param+"]")
+	}
+
+	switch v := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(v)
+		if schema.MinLength != nil && length < *schema.MinLength {
+			fail("minLength", strconv.Itoa(*schema.MinLength), "must be at least "+strconv.Itoa(*schema.MinLength)+" characters")
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			fail("maxLength", strconv.Itoa(*schema.MaxLength), "must be at most "+strconv.Itoa(*schema.MaxLength)+" characters")
+		}
+		if schema.Pattern != "" {
+			re, err := compileRegex(schema.Pattern)
+			if err != nil {
+				fail("pattern", schema.Pattern, "has an invalid pattern: "+err.Error())
+			} else if !re.MatchString(v) {
+				fail("pattern", schema.Pattern, "must match "+schema.Pattern)
+			}
+		}
+		if message, ok := checkFormat(schema.Format, v); !ok {
+			fail("format", schema.Format, message)
+		}
+	case []interface{}:
+		if schema.MinItems != nil && len(v) < *schema.MinItems {
+			fail("minItems", strconv.Itoa(*schema.MinItems), "must be at least "+strconv.Itoa(*schema.MinItems)+" items")
+		}
+		if schema.MaxItems != nil && len(v) > *schema.MaxItems {
+			fail("maxItems", strconv.Itoa(*schema.MaxItems), "must be at most "+strconv.Itoa(*schema.MaxItems)+" items")
+		}
+		for i, item := range v {
+			doc.validateValue(schema.Items, item, field+"["+strconv.Itoa(i)+"]", errs, depth+1)
+		}
+	case map[string]interface{}:
+		for _, name := range schema.Required {
+			if _, ok := v[name]; !ok {
+				*errs = append(*errs, &FieldError{Field: joinField(field, name), Rule: "required", Message: "is required"})
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			property, known := schema.Properties[key]
+			if !known {
+				property = schema.AdditionalProperties
+			}
+			doc.validateValue(property, v[key], joinField(field, key), errs, depth+1)
+		}
+	default:
+		n, ok := jsonNumber(value)
+		if !ok {
+			return
+		}
+		bound := func(limit *float64, rule, message string, valid bool) {
+			if limit != nil && !valid {
+				param := strconv.FormatFloat(*limit, 'f', -1, 64)
+				fail(rule, param, message+param)
+			}
+		}
+		bound(schema.Minimum, "minimum", "must be at least ", schema.Minimum == nil || n >= *schema.Minimum)
+		bound(schema.Maximum, "maximum", "must be at most ", schema.Maximum == nil || n <= *schema.Maximum)
+		bound(schema.ExclusiveMinimum, "exclusiveMinimum", "must be greater than ", schema.ExclusiveMinimum == nil || n > *schema.ExclusiveMinimum)
+		bound(schema.ExclusiveMaximum, "exclusiveMaximum", "must be less than ", schema.ExclusiveMaximum == nil || n < *schema.ExclusiveMaximum)
+	}
+}
+
+// matching counts the schemas value is valid against
+func (doc *OpenAPIDocument) matching(schemas []*Schema, value interface{}, depth int) int {
+	count := 0
+	for _, schema := range schemas {
+		var errs ValidationErrors
+		doc.validateValue(schema, value, "", &errs, depth+1)
+		if len(errs) == 0 {
+			count++
+		}
+	}
+	return count
+}
+
+func hasJSONType(value interface{}, schemaType string) bool {
+	switch schemaType {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := jsonNumber(value)
+		return ok
+	case "integer":
+		n, ok := jsonNumber(value)
+		return ok && n == math.Trunc(n)
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+func jsonNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	if n, ok := jsonNumber(value); ok {
+		value = n
+	}
+	for _, option := range enum {
+		if n, ok := jsonNumber(option); ok {
+			option = n
+		}
+		if reflect.DeepEqual(option, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFormat checks the formats shared with the validate rules
+func checkFormat(format, s string) (string, bool) {
+	switch format {
+	case "email":
+		addr, err := mail.ParseAddress(s)
+		return "must be a valid email address", err == nil && addr.Address == s
+	case "uri":
+		u, err := url.ParseRequestURI(s)
+		return "must be a valid URI", err == nil && u.Scheme != ""
+	case "uuid":
+		return "must be a valid UUID", uuidRegex.MatchString(s)
+	case "date":
+		_, err := time.Parse(time.DateOnly, s)
+		return "must be a valid date", err == nil
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, s)
+		return "must be a valid date-time", err == nil
+	}
+	return "", true
+}
+
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
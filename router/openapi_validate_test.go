@@ -0,0 +1,75 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateValue(t *testing.T) {
+	var doc OpenAPIDocument
+	err := json.Unmarshal([]byte(`{
+		"openapi": "3.1.0",
+		"paths": {},
+		"components": {"schemas": {
+			"Tag": {"type": "string", "pattern": "^[a-z]+$"},
+			"Order": {
+				"type": "object",
+				"required": ["qty"],
+				"properties": {
+					"qty": {"type": "integer", "exclusiveMinimum": 0, "maximum": 10},
+					"tags": {"type": "array", "items": {"$ref": "#/components/schemas/Tag"}},
+					"note": {"type": ["string", "null"], "maxLength": 4},
+					"when": {"type": "string", "format": "date-time"},
+					"ref": {"oneOf": [{"type": "integer"}, {"type": "string", "format": "uuid"}]}
+				},
+				"additionalProperties": false
+			},
+			"Legacy": {"type": "number", "minimum": 1, "exclusiveMinimum": true}
+		}}
+	}`), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := &Schema{Ref: "#/components/schemas/Order"}
+
+	tests := []struct {
+		name       string
+		schema     *Schema
+		value      string
+		wantFields []string
+	}{
+		{"Valid", order, `{"qty":3,"tags":["a"],"note":null,"when":"2024-01-02T03:04:05Z","ref":7}`, nil},
+		{"Missing required", order, `{}`, []string{"qty"}},
+		{"Bounds", order, `{"qty":0,"note":"toolong"}`, []string{"note", "qty"}},
+		{"Integer", order, `{"qty":1.5}`, []string{"qty"}},
+		{"Nested reference", order, `{"qty":1,"tags":["ok","NO"]}`, []string{"tags[1]"}},
+		{"Unknown property", order, `{"qty":1,"extra":true}`, []string{"extra"}},
+		{"Format", order, `{"qty":1,"when":"yesterday"}`, []string{"when"}},
+		{"OneOf", order, `{"qty":1,"ref":"nope"}`, []string{"ref"}},
+		{"Not an object", order, `[1]`, []string{""}},
+		{"3.0 exclusive minimum", &Schema{Ref: "#/components/schemas/Legacy"}, `1`, []string{""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.value), &value); err != nil {
+				t.Fatal(err)
+			}
+			err := doc.ValidateValue(tt.schema, value, "")
+
+			var fields []string
+			var errs ValidationErrors
+			if errors.As(err, &errs) {
+				for _, e := range errs {
+					fields = append(fields, e.Field)
+				}
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.wantFields, ",") || (err == nil) != (tt.wantFields == nil) {
+				t.Errorf("got %v, want fields %v", err, tt.wantFields)
+			}
+		})
+	}
+}
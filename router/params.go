@@ -0,0 +1,185 @@
+package router
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QueryAll returns every value of the query key, in order. Values sent with
+// the array syntax (ids[]=1&ids[]=2) are included.
+func (p *NinaParamsRequest) QueryAll(key string) []string {
+	return allValues(p.query, key)
+}
+
+// FormAll returns every value of the url-encoded form field key, in order.
+// Values sent with the array syntax (ids[]=1&ids[]=2) are included.
+func (p *NinaParamsRequest) FormAll(key string) []string {
+	return allValues(p.formValues(), key)
+}
+
+// QueryMap collects the query keys using the nested syntax under key, so
+// ?filter[status]=open&filter[owner]=me gives {"status": "open", "owner": "me"}.
+func (p *NinaParamsRequest) QueryMap(key string) map[string]string {
+	return mapValues(p.query, key)
+}
+
+// FormMap is QueryMap for the url-encoded form fields.
+func (p *NinaParamsRequest) FormMap(key string) map[string]string {
+	return mapValues(p.formValues(), key)
+}
+
+// formValues parses the body if it was not parsed yet, a body that fails to
+// parse has no form values
+func (p *NinaParamsRequest) formValues() url.Values {
+	if p.request != nil && p.form == nil {
+		p.request.loadBody()
+	}
+	return p.form
+}
+
+// QueryInt returns the query key as an int, def when it is missing. An
+// invalid value returns def along with the conversion error.
+func (p *NinaParamsRequest) QueryInt(key string, def int) (int, error) {
+	value, ok := p.firstQuery(key)
+	if !ok {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return def, &BindingError{Source: tagQuery, Field: key, Value: value, Err: err}
+	}
+	return n, nil
+}
+
+// QueryBool returns the query key as a bool, def when it is missing. An
+// invalid value returns def along with the conversion error.
+func (p *NinaParamsRequest) QueryBool(key string, def bool) (bool, error) {
+	value, ok := p.firstQuery(key)
+	if !ok {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return def, &BindingError{Source: tagQuery, Field: key, Value: value, Err: err}
+	}
+	return b, nil
+}
+
+// QueryDuration returns the query key parsed with time.ParseDuration, def when
+// it is missing. An invalid value returns def along with the parse error.
+func (p *NinaParamsRequest) QueryDuration(key string, def time.Duration) (time.Duration, error) {
+	value, ok := p.firstQuery(key)
+	if !ok {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return def, &BindingError{Source: tagQuery, Field: key, Value: value, Err: err}
+	}
+	return d, nil
+}
+
+// Param returns the path param name, "" when the route has none.
+func (nr *NinaRequest) Param(name string) string {
+	if nr.Params == nil {
+		return ""
+	}
+	return nr.Params.UriParams[name]
+}
+
+// ParamInt returns the path param name as an int. Routes declaring it as
+// {name:int} never see the conversion error.
+func (nr *NinaRequest) ParamInt(name string) (int, error) {
+	value, err := nr.requiredParam(name)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, &BindingError{Source: tagURI, Field: name, Value: value, Err: err}
+	}
+	return n, nil
+}
+
+// ParamInt64 returns the path param name as an int64.
+func (nr *NinaRequest) ParamInt64(name string) (int64, error) {
+	value, err := nr.requiredParam(name)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, &BindingError{Source: tagURI, Field: name, Value: value, Err: err}
+	}
+	return n, nil
+}
+
+// ParamFloat returns the path param name as a float64.
+func (nr *NinaRequest) ParamFloat(name string) (float64, error) {
+	value, err := nr.requiredParam(name)
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, &BindingError{Source: tagURI, Field: name, Value: value, Err: err}
+	}
+	return f, nil
+}
+
+func (nr *NinaRequest) requiredParam(name string) (string, error) {
+	value := nr.Param(name)
+	if value == "" {
+		return "", &BindingError{Source: tagURI, Field: name, Err: errors.New("missing path param")}
+	}
+	return value, nil
+}
+
+func (p *NinaParamsRequest) firstQuery(key string) (string, bool) {
+	values := p.QueryAll(key)
+	if len(values) == 0 || values[0] == "" {
+		return "", false
+	}
+	return values[0], true
+}
+
+func allValues(values url.Values, key string) []string {
+	plain, array := values[key], values[key+"[]"]
+	if len(array) == 0 {
+		return plain
+	}
+	all := make([]string, 0, len(plain)+len(array))
+	all = append(all, plain...)
+	return append(all, array...)
+}
+
+func mapValues(values url.Values, key string) map[string]string {
+	result := make(map[string]string)
+	prefix := key + "["
+	for name, vals := range values {
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, "]") || len(vals) == 0 {
+			continue
+		}
+		if sub := name[len(prefix) : len(name)-1]; sub != "" {
+			result[sub] = vals[0]
+		}
+	}
+	return result
+}
+
+// normalizeValues rewrites the array and nested syntaxes to the names the
+// binders look up: ids[] becomes ids and filter[status] becomes filter.status
+func normalizeValues(values url.Values) url.Values {
+	normalized := make(url.Values, len(values))
+	for name, vals := range values {
+		key := strings.TrimSuffix(name, "[]")
+		if open := strings.Index(key, "["); open > 0 && strings.HasSuffix(key, "]") {
+			key = key[:open] + "." + strings.ReplaceAll(strings.TrimSuffix(key[open+1:], "]"), "][", ".")
+		}
+		normalized[key] = append(normalized[key], vals...)
+	}
+	return normalized
+}
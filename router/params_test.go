@@ -0,0 +1,95 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMultiValueParams(t *testing.T) {
+	nr := NewRouter()
+
+	var params *NinaParamsRequest
+	nr.POST("/search", func(w http.ResponseWriter, r *NinaRequest) {
+		params = r.Params
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/search?tag=a&tag=b&ids[]=1&ids[]=2&filter[status]=open&filter[owner]=me&page=3&debug=true&wait=2s&bad=x", strings.NewReader("color=red&color=blue&opts[size]=xl"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	nr.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got := strings.Join(params.QueryAll("tag"), ","); got != "a,b" {
+		t.Errorf("got tags %v, want a,b", got)
+	}
+	if got := strings.Join(params.QueryAll("ids"), ","); got != "1,2" {
+		t.Errorf("got ids %v, want 1,2", got)
+	}
+	if got := params.QueryMap("filter"); got["status"] != "open" || got["owner"] != "me" || len(got) != 2 {
+		t.Errorf("got filter %v", got)
+	}
+	if got := strings.Join(params.FormAll("color"), ","); got != "red,blue" {
+		t.Errorf("got colors %v, want red,blue", got)
+	}
+	if got := params.FormMap("opts"); got["size"] != "xl" {
+		t.Errorf("got opts %v", got)
+	}
+	if params.QueryString["tag"] != "a" {
+		t.Errorf("QueryString should keep the first value, got %v", params.QueryString["tag"])
+	}
+
+	if page, err := params.QueryInt("page", 1); page != 3 || err != nil {
+		t.Errorf("got page %v %v, want 3", page, err)
+	}
+	if page, err := params.QueryInt("missing", 1); page != 1 || err != nil {
+		t.Errorf("got missing %v %v, want default 1", page, err)
+	}
+	if bad, err := params.QueryInt("bad", 7); bad != 7 || err == nil {
+		t.Errorf("got bad %v %v, want default 7 with an error", bad, err)
+	}
+	if debug, err := params.QueryBool("debug", false); !debug || err != nil {
+		t.Errorf("got debug %v %v, want true", debug, err)
+	}
+	if _, err := params.QueryBool("bad", false); err == nil {
+		t.Error("expected an error for an invalid bool")
+	}
+	if wait, err := params.QueryDuration("wait", time.Second); wait != 2*time.Second || err != nil {
+		t.Errorf("got wait %v %v, want 2s", wait, err)
+	}
+	if wait, err := params.QueryDuration("missing", time.Second); wait != time.Second || err != nil {
+		t.Errorf("got wait %v %v, want default 1s", wait, err)
+	}
+}
+
+func TestBindArrayAndNestedSyntax(t *testing.T) {
+	type filter struct {
+		Status string `query:"status" form:"status"`
+	}
+	type search struct {
+		IDs    []int    `query:"ids"`
+		Filter filter   `query:"filter"`
+		Colors []string `form:"color"`
+		Opts   filter   `form:"opts"`
+	}
+
+	nr := NewRouter()
+	nr.POST("/search", func(w http.ResponseWriter, r *NinaRequest) {
+		var s search
+		if err := r.Bind(&s); err != nil {
+			fmt.Fprint(w, err)
+			return
+		}
+		fmt.Fprintf(w, "%v|%s|%v|%s", s.IDs, s.Filter.Status, s.Colors, s.Opts.Status)
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/search?ids[]=1&ids[]=2&filter[status]=open", strings.NewReader("color=red&color=blue&opts[status]=closed"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	nr.ServeHTTP(rr, req)
+
+	if want := "[1 2]|open|[red blue]|closed"; rr.Body.String() != want {
+		t.Errorf("got %v, want %v", rr.Body.String(), want)
+	}
+}
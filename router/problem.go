@@ -0,0 +1,138 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ProblemContentType is the media type of RFC 9457 problem details.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 9457 problem details document. Extensions are serialized
+// as top level members next to the standard ones.
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
+}
+
+// NewProblem creates a problem of type about:blank for status.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{}, len(p.Extensions)+5)
+	for key, value := range p.Extensions {
+		members[key] = value
+	}
+
+	// Standard members always win over extensions with the same name
+	typeURI := p.Type
+	if typeURI == "" {
+		typeURI = "about:blank"
+	}
+	members["type"] = typeURI
+	if p.Title != "" {
+		members["title"] = p.Title
+	}
+	if p.Status != 0 {
+		members["status"] = p.Status
+	}
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+	return json.Marshal(members)
+}
+
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	*p = Problem{}
+	for key, raw := range members {
+		var err error
+		switch key {
+		case "type":
+			err = json.Unmarshal(raw, &p.Type)
+		case "title":
+			err = json.Unmarshal(raw, &p.Title)
+		case "status":
+			err = json.Unmarshal(raw, &p.Status)
+		case "detail":
+			err = json.Unmarshal(raw, &p.Detail)
+		case "instance":
+			err = json.Unmarshal(raw, &p.Instance)
+		default:
+			var value interface{}
+			err = json.Unmarshal(raw, &value)
+			if p.Extensions == nil {
+				p.Extensions = make(map[string]interface{})
+			}
+			p.Extensions[key] = value
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Problem converts the error into problem details for the request r. The code
+// and details of the error become the code and details extension members.
+func (e *HTTPError) Problem(r *NinaRequest) *Problem {
+	p := NewProblem(e.Status, "")
+	if e.Type != "" {
+		p.Type = e.Type
+	}
+	if e.Message != p.Title {
+		p.Detail = e.Message
+	}
+	if r != nil && r.Request != nil && r.URL != nil {
+		p.Instance = r.URL.Path
+	}
+
+	p.Extensions = make(map[string]interface{}, len(e.Extensions)+2)
+	for key, value := range e.Extensions {
+		p.Extensions[key] = value
+	}
+	if e.Code != "" {
+		p.Extensions["code"] = e.Code
+	}
+	if e.Details != nil {
+		p.Extensions["details"] = e.Details
+	}
+	return p
+}
+
+// WriteProblem sends p as application/problem+json.
+func WriteProblem(w http.ResponseWriter, p *Problem) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		// Some extension could not be encoded, keep the standard members only
+		body, _ = json.Marshal(&Problem{Type: p.Type, Title: p.Title, Status: p.Status, Detail: p.Detail, Instance: p.Instance})
+	}
+
+	status := p.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
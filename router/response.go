@@ -0,0 +1,262 @@
+package router
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrNotAcceptable is returned by Negotiate when the Accept header allows
+// none of the media types the response can be encoded to.
+var ErrNotAcceptable = errors.New("no acceptable media type for the response")
+
+// EncodeError is returned by the response helpers when the value cannot be
+// encoded. Nothing has been written to the client at that point, so the caller
+// is still free to send an error response.
+type EncodeError struct {
+	ContentType string
+	Err         error
+}
+
+func (e *EncodeError) Error() string {
+	return fmt.Sprintf("encode %s response: %v", e.ContentType, e.Err)
+}
+
+func (e *EncodeError) Unwrap() error {
+	return e.Err
+}
+
+// NinaResponse wraps an http.ResponseWriter with helpers symmetrical to the
+// request parsers and remembers the status code that was sent.
+type NinaResponse struct {
+	http.ResponseWriter
+	request *NinaRequest
+	status  int
+	size    int
+}
+
+// NewResponse wraps w for the request r. Wrapping an existing NinaResponse
+// returns it unchanged so helpers and middlewares share the same state.
+func NewResponse(w http.ResponseWriter, r *NinaRequest) *NinaResponse {
+	if res, ok := w.(*NinaResponse); ok {
+		if res.request == nil {
+			res.request = r
+		}
+		return res
+	}
+	return &NinaResponse{ResponseWriter: w, request: r}
+}
+
+// Response wraps w into a NinaResponse bound to this request.
+func (nr *NinaRequest) Response(w http.ResponseWriter) *NinaResponse {
+	return NewResponse(w, nr)
+}
+
+func (res *NinaResponse) WriteHeader(status int) {
+	if res.status != 0 {
+		return
+	}
+	res.status = status
+	res.ResponseWriter.WriteHeader(status)
+}
+
+func (res *NinaResponse) Write(b []byte) (int, error) {
+	if res.status == 0 {
+		res.WriteHeader(http.StatusOK)
+	}
+	n, err := res.ResponseWriter.Write(b)
+	res.size += n
+	return n, err
+}
+
+// Status returns the status code sent, 0 when nothing was written yet.
+func (res *NinaResponse) Status() int {
+	return res.status
+}
+
+// Size returns the number of body bytes written.
+func (res *NinaResponse) Size() int {
+	return res.size
+}
+
+// Written reports whether the headers were already sent.
+func (res *NinaResponse) Written() bool {
+	return res.status != 0
+}
+
+// Unwrap lets http.ResponseController reach the original writer.
+func (res *NinaResponse) Unwrap() http.ResponseWriter {
+	return res.ResponseWriter
+}
+
+// Flush sends any buffered data when the underlying writer supports it.
+func (res *NinaResponse) Flush() {
+	if flusher, ok := res.ResponseWriter.(http.Flusher); ok {
+		if res.status == 0 {
+			res.WriteHeader(http.StatusOK)
+		}
+		flusher.Flush()
+	}
+}
+
+// Hijack hands the connection over when the underlying writer supports it.
+func (res *NinaResponse) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := res.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return hijacker.Hijack()
+}
+
+// JSON encodes v as JSON and sends it with status.
+func (res *NinaResponse) JSON(status int, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return &EncodeError{ContentType: "application/json", Err: err}
+	}
+	return res.Blob(status, "application/json; charset=utf-8", b)
+}
+
+// XML encodes v as XML and sends it with status.
+func (res *NinaResponse) XML(status int, v interface{}) error {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		return &EncodeError{ContentType: "application/xml", Err: err}
+	}
+	return res.Blob(status, "application/xml; charset=utf-8", append([]byte(xml.Header), b...))
+}
+
+// Text sends s as plain text.
+func (res *NinaResponse) Text(status int, s string) error {
+	return res.Blob(status, "text/plain; charset=utf-8", []byte(s))
+}
+
+// HTML sends s as an HTML document, s is written as is.
+func (res *NinaResponse) HTML(status int, s string) error {
+	return res.Blob(status, "text/html; charset=utf-8", []byte(s))
+}
+
+// Blob sends b with the given content type.
+func (res *NinaResponse) Blob(status int, contentType string, b []byte) error {
+	header := res.Header()
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Length", strconv.Itoa(len(b)))
+	res.WriteHeader(status)
+	if res.request != nil && res.request.Method == http.MethodHead {
+		return nil
+	}
+	_, err := res.Write(b)
+	return err
+}
+
+// NoContent sends a 204 without body.
+func (res *NinaResponse) NoContent() error {
+	res.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// Redirect replies with a redirect to url, status must be a 3xx code.
+func (res *NinaResponse) Redirect(status int, url string) error {
+	if status < http.StatusMultipleChoices || status > http.StatusPermanentRedirect {
+		return fmt.Errorf("invalid redirect status code %d", status)
+	}
+	var r *http.Request
+	if res.request != nil {
+		r = res.request.Request
+	}
+	if r == nil {
+		res.Header().Set("Location", url)
+		res.WriteHeader(status)
+		return nil
+	}
+	http.Redirect(res, r, url, status)
+	return nil
+}
+
+// Negotiate encodes v as JSON or XML depending on the request Accept header,
+// JSON being preferred when the client accepts both or sent no Accept header.
+// It returns ErrNotAcceptable, without writing anything, when neither fits.
+func (res *NinaResponse) Negotiate(status int, v interface{}) error {
+	accept := ""
+	if res.request != nil && res.request.Header != nil {
+		accept = res.request.Header.Get("Accept")
+	}
+
+	switch negotiateMediaType(accept, []string{"application/json", "application/xml", "text/xml"}) {
+	case "application/json":
+		return res.JSON(status, v)
+	case "application/xml", "text/xml":
+		return res.XML(status, v)
+	default:
+		return ErrNotAcceptable
+	}
+}
+
+type acceptRange struct {
+	mediaType string
+	quality   float64
+}
+
+// negotiateMediaType returns the offer preferred by the Accept header, ties
+// are won by the order of offers. An empty header accepts the first offer.
+func negotiateMediaType(accept string, offers []string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(fields[0]))
+		if mediaType == "" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, quality: quality})
+	}
+
+	best, bestQuality, bestSpecificity := "", 0.0, -1
+	for _, offer := range offers {
+		quality, specificity := 0.0, -1
+		for _, ar := range ranges {
+			if s := matchMediaRange(ar.mediaType, offer); s > specificity {
+				quality, specificity = ar.quality, s
+			}
+		}
+		if specificity < 0 || quality <= 0 {
+			continue
+		}
+		if quality > bestQuality || (quality == bestQuality && specificity > bestSpecificity) {
+			best, bestQuality, bestSpecificity = offer, quality, specificity
+		}
+	}
+	return best
+}
+
+// matchMediaRange returns how specific the range is for offer, -1 when it does not match
+func matchMediaRange(mediaRange, offer string) int {
+	switch {
+	case mediaRange == offer:
+		return 2
+	case mediaRange == "*/*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(mediaRange, "*")):
+		return 1
+	default:
+		return -1
+	}
+}
@@ -0,0 +1,118 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type responsePayload struct {
+	XMLName struct{} `json:"-" xml:"payload"`
+	Name    string   `json:"name" xml:"name"`
+}
+
+func TestResponseHelpers(t *testing.T) {
+	nr := NewRouter()
+	nr.GET("/json", func(w http.ResponseWriter, r *NinaRequest) {
+		r.Response(w).JSON(http.StatusCreated, responsePayload{Name: "nina"})
+	}, nil)
+	nr.GET("/xml", func(w http.ResponseWriter, r *NinaRequest) {
+		r.Response(w).XML(http.StatusOK, responsePayload{Name: "nina"})
+	}, nil)
+	nr.GET("/text", func(w http.ResponseWriter, r *NinaRequest) {
+		r.Response(w).Text(http.StatusOK, "hello")
+	}, nil)
+	nr.GET("/html", func(w http.ResponseWriter, r *NinaRequest) {
+		r.Response(w).HTML(http.StatusOK, "<p>hello</p>")
+	}, nil)
+	nr.GET("/blob", func(w http.ResponseWriter, r *NinaRequest) {
+		r.Response(w).Blob(http.StatusOK, "application/octet-stream", []byte{1, 2, 3})
+	}, nil)
+	nr.GET("/empty", func(w http.ResponseWriter, r *NinaRequest) {
+		r.Response(w).NoContent()
+	}, nil)
+	nr.GET("/redirect", func(w http.ResponseWriter, r *NinaRequest) {
+		r.Response(w).Redirect(http.StatusFound, "/text")
+	}, nil)
+	nr.GET("/negotiate", func(w http.ResponseWriter, r *NinaRequest) {
+		if err := r.Response(w).Negotiate(http.StatusOK, responsePayload{Name: "nina"}); errors.Is(err, ErrNotAcceptable) {
+			http.Error(w, err.Error(), http.StatusNotAcceptable)
+		}
+	}, nil)
+	nr.GET("/broken", func(w http.ResponseWriter, r *NinaRequest) {
+		err := r.Response(w).JSON(http.StatusOK, map[string]interface{}{"f": func() {}})
+		var encodeErr *EncodeError
+		if errors.As(err, &encodeErr) {
+			http.Error(w, "encode failed", http.StatusInternalServerError)
+		}
+	}, nil)
+
+	tests := []struct {
+		url             string
+		accept          string
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{"/json", "", http.StatusCreated, "application/json; charset=utf-8", `{"name":"nina"}`},
+		{"/xml", "", http.StatusOK, "application/xml; charset=utf-8", "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<payload><name>nina</name></payload>"},
+		{"/text", "", http.StatusOK, "text/plain; charset=utf-8", "hello"},
+		{"/html", "", http.StatusOK, "text/html; charset=utf-8", "<p>hello</p>"},
+		{"/blob", "", http.StatusOK, "application/octet-stream", "\x01\x02\x03"},
+		{"/empty", "", http.StatusNoContent, "", ""},
+		{"/negotiate", "", http.StatusOK, "application/json; charset=utf-8", `{"name":"nina"}`},
+		{"/negotiate", "application/xml, application/json;q=0.5", http.StatusOK, "application/xml; charset=utf-8", "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<payload><name>nina</name></payload>"},
+		{"/negotiate", "text/*", http.StatusOK, "application/xml; charset=utf-8", "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<payload><name>nina</name></payload>"},
+		{"/negotiate", "*/*", http.StatusOK, "application/json; charset=utf-8", `{"name":"nina"}`},
+		{"/negotiate", "text/html", http.StatusNotAcceptable, "text/plain; charset=utf-8", "no acceptable media type for the response\n"},
+		{"/broken", "", http.StatusInternalServerError, "text/plain; charset=utf-8", "encode failed\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url+" "+tt.accept, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+
+			nr.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %v, want %v", rr.Code, tt.wantStatus)
+			}
+			if got := rr.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("got content type %v, want %v", got, tt.wantContentType)
+			}
+			if rr.Body.String() != tt.wantBody {
+				t.Errorf("got body %q, want %q", rr.Body.String(), tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("redirect", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		nr.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/redirect", nil))
+		if rr.Code != http.StatusFound || rr.Header().Get("Location") != "/text" {
+			t.Errorf("got %v to %q, want 302 to /text", rr.Code, rr.Header().Get("Location"))
+		}
+	})
+}
+
+func TestResponseTracksStatus(t *testing.T) {
+	rr := httptest.NewRecorder()
+	res := NewResponse(rr, nil)
+	if res.Written() {
+		t.Fatal("fresh response should not be written")
+	}
+	res.Write([]byte("abc"))
+	res.WriteHeader(http.StatusTeapot)
+
+	if res.Status() != http.StatusOK || res.Size() != 3 || rr.Code != http.StatusOK {
+		t.Errorf("got status %v size %v, want 200 and 3", res.Status(), res.Size())
+	}
+	if NewResponse(res, nil) != res {
+		t.Error("wrapping a NinaResponse should return it unchanged")
+	}
+}
@@ -0,0 +1,139 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrRouteNotFound is returned by URL when no route has the given name.
+var ErrRouteNotFound = errors.New("router: no route with this name")
+
+// Route is a registered route, returned by Handle and the verb helpers.
+type Route struct {
+	router  *ServeMux
+	pattern string
+	methods []string
+	rp      *routePattern
+	name    string
+	// kept for Routes and OpenAPI
+	handler     Handler
+	handlerName string
+	middlewares []Middleware
+	doc         routeDoc
+}
+
+// mountPoint is a router mounted with Mount
+type mountPoint struct {
+	prefix string
+	sub    *ServeMux
+}
+
+// Name names the route so URL can build its path. Names are unique per
+// router, naming two routes the same panics. The name is also the OpenAPI
+// operationId, suffixed with the lowercase method when the route serves
+// several methods.
+func (rt *Route) Name(name string) *Route {
+	mux := rt.router
+	if existing, found := mux.named[name]; found && existing != rt {
+		panic(fmt.Sprintf("router: route name %q already used by %q", name, existing.pattern))
+	}
+	if mux.named == nil {
+		mux.named = make(map[string]*Route)
+	}
+	if rt.name != "" {
+		delete(mux.named, rt.name)
+	}
+	rt.name = name
+	mux.named[name] = rt
+	return rt
+}
+
+// Pattern returns the pattern the route was registered with, group prefix
+// included.
+func (rt *Route) Pattern() string {
+	return rt.pattern
+}
+
+// Methods returns the methods of the route, nil when it accepts any method.
+func (rt *Route) Methods() []string {
+	return rt.methods
+}
+
+// URL builds the path of the route named name, with its params escaped and
+// query appended. Routes of mounted routers are found too, and the path
+// includes the mount prefixes when mux itself is mounted.
+func (mux *ServeMux) URL(name string, params map[string]string, query url.Values) (string, error) {
+	path, err := mux.routePath(name, params)
+	if err != nil {
+		return "", err
+	}
+	for m := mux; m.parent != nil; m = m.parent {
+		path = m.mountPrefix + path
+	}
+	if encoded := query.Encode(); encoded != "" {
+		path += "?" + encoded
+	}
+	return path, nil
+}
+
+// routePath builds the path of name relative to mux, looking into the
+// mounted routers when mux has no such route
+func (mux *ServeMux) routePath(name string, params map[string]string) (string, error) {
+	if rt, found := mux.named[name]; found {
+		return rt.path(params)
+	}
+	for _, mount := range mux.mounts {
+		path, err := mount.sub.routePath(name, params)
+		if err == nil {
+			return mount.prefix + path, nil
+		}
+		if !errors.Is(err, ErrRouteNotFound) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrRouteNotFound, name)
+}
+
+// path fills the pattern of the route with params
+func (rt *Route) path(params map[string]string) (string, error) {
+	var b strings.Builder
+	var err error
+	i := 0
+	eachPatternParam(rt.pattern, func(literal, inner string) {
+		b.WriteString(literal)
+		if inner == "" || inner == "$" || err != nil {
+			return
+		}
+		param := rt.rp.params[i]
+		i++
+
+		value, ok := params[param.name]
+		switch {
+		case !ok || (value == "" && !param.wildcard):
+			err = fmt.Errorf("router: missing param %q for route %q", param.name, rt.name)
+		case param.match != nil && !param.match(value):
+			err = fmt.Errorf("router: param %q of route %q does not match its constraint: %q", param.name, rt.name, value)
+		case param.wildcard:
+			// Keep the slashes of the remaining path
+			segments := strings.Split(value, "/")
+			for j, segment := range segments {
+				segments[j] = url.PathEscape(segment)
+			}
+			b.WriteString(strings.Join(segments, "/"))
+		default:
+			b.WriteString(url.PathEscape(value))
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+
+	path := b.String()
+	if idx := strings.Index(path, "/"); idx > 0 {
+		// Host patterns, the path starts at the first slash
+		path = path[idx:]
+	}
+	return path, nil
+}
@@ -0,0 +1,96 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestURL(t *testing.T) {
+	noop := func(w http.ResponseWriter, r *NinaRequest) {}
+
+	nr := NewRouter()
+	nr.GET("/{$}", noop, nil).Name("home")
+	nr.GET("/users/{id:int}", noop, nil).Name("user.show")
+	nr.GET("/files/{path...}", noop, nil).Name("file")
+	nr.GET("/exact/{$}", noop, nil).Name("exact")
+	nr.GET("/tags/{name}", noop, nil).Name("tag")
+	api := nr.GROUP("/api", nil, nil).GROUP("/v1", nil, nil)
+	api.POST("/orgs/{org}/members/{member}", noop, nil).Name("member.create")
+
+	billing := NewRouter()
+	billing.GET("/invoices/{id}", noop, nil).Name("invoice.show")
+	nr.Mount("/billing", billing)
+
+	tests := []struct {
+		name    string
+		mux     *ServeMux
+		route   string
+		params  map[string]string
+		query   url.Values
+		want    string
+		wantErr bool
+	}{
+		{"Static", nr, "home", nil, nil, "/", false},
+		{"Param with query", nr, "user.show", map[string]string{"id": "42"}, url.Values{"tab": {"posts"}, "q": {"a b"}}, "/users/42?q=a+b&tab=posts", false},
+		{"Escaped param", nr, "tag", map[string]string{"name": "c/c++ & go"}, nil, "/tags/c%2Fc++%20&%20go", false},
+		{"Wildcard keeps slashes", nr, "file", map[string]string{"path": "docs/read me.md"}, nil, "/files/docs/read%20me.md", false},
+		{"Exact match", nr, "exact", nil, nil, "/exact/", false},
+		{"Nested groups", nr, "member.create", map[string]string{"org": "nina", "member": "7"}, nil, "/api/v1/orgs/nina/members/7", false},
+		{"Mounted from parent", nr, "invoice.show", map[string]string{"id": "9"}, nil, "/billing/invoices/9", false},
+		{"Mounted from sub", billing, "invoice.show", map[string]string{"id": "9"}, nil, "/billing/invoices/9", false},
+		{"Missing param", nr, "user.show", nil, nil, "", true},
+		{"Constraint mismatch", nr, "user.show", map[string]string{"id": "nina"}, nil, "", true},
+		{"Unknown route", nr, "nope", nil, nil, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.mux.URL(tt.route, tt.params, tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := nr.URL("nope", nil, nil); !errors.Is(err, ErrRouteNotFound) {
+		t.Errorf("got %v, want ErrRouteNotFound", err)
+	}
+}
+
+func TestURLRoundTrip(t *testing.T) {
+	nr := NewRouter()
+	nr.GET("/tags/{name}", func(w http.ResponseWriter, r *NinaRequest) {
+		w.Write([]byte(r.Param("name")))
+	}, nil).Name("tag")
+
+	for _, name := range []string{"go", "c/c++", "50% off", "naïve?"} {
+		t.Run(name, func(t *testing.T) {
+			path, err := nr.URL("tag", map[string]string{"name": name}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			nr.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+			if rr.Body.String() != name {
+				t.Errorf("got %v through %v, want %v", rr.Body.String(), path, name)
+			}
+		})
+	}
+}
+
+func TestDuplicateRouteName(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	nr := NewRouter()
+	nr.GET("/a", func(w http.ResponseWriter, r *NinaRequest) {}, nil).Name("same")
+	nr.GET("/b", func(w http.ResponseWriter, r *NinaRequest) {}, nil).Name("same")
+}
@@ -4,20 +4,56 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
-	"encoding/xml"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"mime/multipart"
 	"net/http"
 	"net/url"
-	"regexp"
 	"strings"
+	"sync"
 )
 
 type ServeMux struct {
 	*http.ServeMux
+	// ErrorHandler renders the errors returned by HandlerE handlers, body
+	// parsing failures and built-in middlewares. DefaultErrorHandler is used
+	// when nil.
+	ErrorHandler ErrorHandler
+
+	notFound         Handler
+	methodNotAllowed Handler
+	// extra methods registered through Handle, listed in Allow after the
+	// standard ones
+	customMethods []string
+	// paths of the routes of each method, matched to build Allow
+	methodRoutes []methodRoute
+	// router level middlewares added with Use
+	middlewares []Middleware
+	// routes by method and shape, see handleRoute
+	routes map[string]*routeEntry
+	// every route in registration order, see Routes
+	routeList []*Route
+	// named routes and mounted routers, used by URL
+	named       map[string]*Route
+	mounts      []mountPoint
+	parent      *ServeMux
+	mountPrefix string
+	// OpenAPIInfo is the info object of the document built by OpenAPI
+	OpenAPIInfo     OpenAPIInfo
+	securitySchemes map[string]*SecurityScheme
+	// MaxBodyBytes limits the size of the request bodies, larger bodies are
+	// rejected with 413 when read. 0 means no limit. Groups and routes can
+	// change it with NinaRequest.LimitBody, see middleware.MaxBodyBytesMiddleware.
+	MaxBodyBytes int64
+	// Multipart holds the upload limits applied by Bind, FormFile and Files.
+	// ParseMultipart and EachPart take their own.
+	Multipart MultipartOptions
+	// body decoders by media type, nil until RegisterDecoder is called
+	decoders map[string]Decoder
 }
 
 type Handler func(w http.ResponseWriter, r *NinaRequest)
@@ -34,15 +70,11 @@ func NewRouter() *ServeMux {
 	mux := &ServeMux{
 		ServeMux: http.NewServeMux(),
 	}
+	mux.notFound = mux.chain(defaultNotFound)
+	mux.methodNotAllowed = mux.chain(defaultMethodNotAllowed)
 	return mux
 }
 
-type GenericXML struct {
-	XMLName xml.Name
-	Content string       `xml:",chardata"`
-	Nodes   []GenericXML `xml:",any"`
-}
-
 type NinaRequest struct {
 	*http.Request
 	Header        http.Header
@@ -61,16 +93,44 @@ type NinaRequest struct {
 	Pattern       map[string]string
 	Params        *NinaParamsRequest
 	body          interface{}
-	ValidatedData map[string]string
+	rawBody       []byte
+	router        *ServeMux
+	// the body is parsed on first use by loadBody, see GetBody and Bind
+	bodyLoaded bool
+	bodyErr    error
+	streamBody bool
+	bodySource io.ReadCloser // request body before any limit
+	Validated  interface{}   // struct filled by Validate once every rule passed
 }
 
+// NinaParamsRequest holds the query and path params of a request. The maps
+// are nil when empty and Params shares its map with QueryString or UriParams
+// when only one of them has values, treat them as read only.
 type NinaParamsRequest struct {
-	QueryString map[string]string
+	QueryString map[string]string // first value of every query key, see QueryAll
 	UriParams   map[string]string
-	Params      map[string]string
+	Params      map[string]string // QueryString and UriParams, path params win
+	query       url.Values
+	form        url.Values
+	request     *NinaRequest // loads the form on first use
 }
 
+// ErrBodyStreamed is returned by GetBody when the route streams its body.
+var ErrBodyStreamed = errors.New("request body is streamed, read it from Body")
+
+// GetBody parses the body into a map on first call, based on the Content-Type
+// header. Parse failures are returned as 400 HTTPErrors and bodies over the
+// MaxBodyBytes limit as 413.
+//
+// Bodies without a registered decoder, text/plain included, are parsed as
+// text/x-nina-kv and kept whole under "rawBody". Text the kv syntax rejects,
+// like an unterminated quote in "hello" world", is a 400 invalid_body; read
+// such bodies with RawBody instead.
 func (nr *NinaRequest) GetBody() (map[string]interface{}, error) {
+	if err := nr.loadBody(); err != nil {
+		return nil, err
+	}
+
 	// Check if the body exists
 	if nr.body == nil {
 		return nil, fmt.Errorf("body is empty or not initialized")
@@ -85,453 +145,393 @@ func (nr *NinaRequest) GetBody() (map[string]interface{}, error) {
 	return body, nil
 }
 
-func (mux *ServeMux) GET(pattern string, handler Handler, middlewares []Middleware) {
-	finalHandler := applyMiddlewares(handler, middlewares...)
-	mux.ServeMux.Handle(http.MethodGet+" "+pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		reqParams := getReqParams(r, pattern)
-		params := &NinaParamsRequest{
-			QueryString: reqParams["queryString"],
-			UriParams:   reqParams["uriParams"],
-			Params:      reqParams["params"],
-		}
+// RawBody returns the body as sent by the client without parsing it. The body
+// stays available to GetBody, Bind and the handler.
+func (nr *NinaRequest) RawBody() ([]byte, error) {
+	switch {
+	case nr.bodyLoaded || nr.rawBody != nil:
+		return nr.rawBody, nr.bodyErr
+	case nr.streamBody:
+		return nil, ErrBodyStreamed
+	}
+	if nr.Request.Body == nil {
+		nr.Request.Body = http.NoBody
+	}
+	bodyBytes, err := readBody(nr.Request)
+	if err != nil {
+		return nil, err
+	}
+	nr.rawBody = bodyBytes
+	return bodyBytes, nil
+}
 
-		ninaRequest := &NinaRequest{
-			Request:       r,
-			Header:        r.Header,
-			Form:          &r.Form,
-			Method:        r.Method,
-			PostForm:      &r.PostForm,
-			ctx:           r.Context(),
-			ContentLength: r.ContentLength,
-			tls:           r.TLS,
-			Proto:         r.Proto,
-			Host:          r.Host,
-			Params:        params,
-			UserAgent:     r.UserAgent(),
-			RemoteAddr:    r.RemoteAddr,
-		}
-		finalHandler(w, ninaRequest)
-	}))
+// ConnectionState returns the TLS state of the connection the request came
+// on, nil for plain HTTP.
+func (nr *NinaRequest) ConnectionState() *tls.ConnectionState {
+	return nr.tls
 }
 
-func (mux *ServeMux) POST(pattern string, handler Handler, middlewares []Middleware) {
-	finalHandler := applyMiddlewares(handler, middlewares...)
-	mux.ServeMux.Handle(http.MethodPost+" "+pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
+// PeerCertificates returns the verified certificate chain of the client, leaf
+// first. It is nil when the client sent no certificate, the server did not
+// verify it or the connection is not TLS, so it can be trusted as is. The
+// server verifies with tls.VerifyClientCertIfGiven and
+// tls.RequireAndVerifyClientCert.
+func (nr *NinaRequest) PeerCertificates() []*x509.Certificate {
+	if nr.tls == nil || len(nr.tls.VerifiedChains) == 0 {
+		return nil
+	}
+	return nr.tls.VerifiedChains[0]
+}
 
-		// Read the request body
-		bodyBytes, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, "Unable to read body", http.StatusBadRequest)
-			return
-		}
-		defer r.Body.Close()
+// UnverifiedPeerCertificates returns the certificates sent by the client as
+// is, leaf first, nil when it sent none or the connection is not TLS. Verify
+// them before trusting them.
+func (nr *NinaRequest) UnverifiedPeerCertificates() []*x509.Certificate {
+	if nr.tls == nil {
+		return nil
+	}
+	return nr.tls.PeerCertificates
+}
 
-		// Restore the body for potential reuse
-		r.Body = ioutil.NopCloser(bytes.NewReader(bodyBytes))
+// LimitBody rejects bodies larger than n bytes with 413, replacing the limit
+// set by the router or a previous call. n <= 0 removes the limit. It has no
+// effect once the body was read.
+func (nr *NinaRequest) LimitBody(w http.ResponseWriter, n int64) {
+	if nr.bodyLoaded || nr.rawBody != nil || nr.bodySource == nil {
+		return
+	}
+	if n <= 0 {
+		nr.Request.Body = nr.bodySource
+		return
+	}
+	nr.Request.Body = http.MaxBytesReader(w, nr.bodySource, n)
+}
 
-		// Parse body into a unified map
-		parsedBody := make(map[string]interface{})
-		contentType := r.Header.Get("Content-Type")
+// StreamBody leaves the body to the handler: the router limit is removed and
+// GetBody returns ErrBodyStreamed instead of buffering it. Bind still fills
+// the URI, query and header fields.
+func (nr *NinaRequest) StreamBody() {
+	if nr.bodyLoaded || nr.rawBody != nil {
+		return
+	}
+	nr.streamBody = true
+	if nr.bodySource != nil {
+		nr.Request.Body = nr.bodySource
+	}
+}
 
-		switch {
-		case contentType == "application/json":
-			// Parse JSON
-			if err := json.Unmarshal(bodyBytes, &parsedBody); err != nil {
-				http.Error(w, "Invalid JSON", http.StatusBadRequest)
-				return
-			}
-		case contentType == "application/xml" || contentType == "text/xml":
-			// Parse the XML into a generic tree structure
-			var root GenericXML
-			if err := xml.Unmarshal(bodyBytes, &root); err != nil {
-				http.Error(w, "Invalid XML", http.StatusBadRequest)
-				return
-			}
+// loadBody parses the body once, the result is kept for the next calls
+func (nr *NinaRequest) loadBody() error {
+	if nr.bodyLoaded {
+		return nr.bodyErr
+	}
+	nr.bodyLoaded = true
+	if nr.streamBody {
+		nr.bodyErr = ErrBodyStreamed
+		return nr.bodyErr
+	}
 
-			// Convert the XML tree to a map
-			parsedBody = xmlToMap(root)
+	parsedBody, bodyBytes, err := nr.router.parseBody(nr.Request)
+	if err != nil {
+		nr.bodyErr = err
+		return err
+	}
+	nr.body = parsedBody
+	nr.rawBody = bodyBytes
+	if nr.Params != nil && nr.Request.PostForm != nil {
+		nr.Params.form = nr.Request.PostForm
+	}
+	return nil
+}
 
-		case contentType == "application/x-www-form-urlencoded":
-			// Parse form data
-			if err := r.ParseForm(); err != nil {
-				http.Error(w, "Unable to parse form data", http.StatusBadRequest)
-				return
-			}
-			for key, values := range r.PostForm {
-				// Add form data to the map (use the first value for simplicity)
-				if len(values) > 0 {
-					parsedBody[key] = values[0]
-				}
-			}
-		default:
-			// For unsupported content types, treat as raw text and try to parse
-			rawBody := string(bodyBytes)
-			parsedMap, err := parseRawBody(rawBody)
-			if err != nil {
-				http.Error(w, fmt.Sprintf("Unable to parse raw body: %v", err), http.StatusBadRequest)
-				return
-			}
-			for key, value := range parsedMap {
-				parsedBody[key] = value
-			}
-			parsedBody["rawBody"] = string(bodyBytes)
-		}
+// Use adds middlewares wrapping every request served by the router: routes,
+// groups, mounted routers and the NotFound and MethodNotAllowed handlers. They
+// run before the route middlewares, from left to right in the order they were
+// added, no matter if the routes were registered before or after the call.
+// Use must be called before the router serves requests, it is not safe for
+// concurrent use and the chains are composed on the first request.
+func (mux *ServeMux) Use(middlewares ...Middleware) {
+	mux.middlewares = append(mux.middlewares, middlewares...)
+}
 
-		// Set up request parameters
-		reqParams := getReqParams(r, pattern)
-		params := &NinaParamsRequest{
-			QueryString: reqParams["queryString"],
-			UriParams:   reqParams["uriParams"],
-			Params:      reqParams["params"],
-		}
+// chain wraps h with the router level middlewares. The chain is composed on
+// the first request, once every Use call is done, and reused afterwards.
+func (mux *ServeMux) chain(h Handler) Handler {
+	var once sync.Once
+	var composed Handler
+	return func(w http.ResponseWriter, r *NinaRequest) {
+		once.Do(func() {
+			composed = applyMiddlewares(h, mux.middlewares...)
+		})
+		composed(w, r)
+	}
+}
 
-		// Create the custom NinaRequest
-		ninaRequest := &NinaRequest{
-			Request:       r,
-			Header:        r.Header,
-			Form:          &r.Form,
-			Method:        r.Method,
-			PostForm:      &r.PostForm,
-			ctx:           r.Context(),
-			ContentLength: r.ContentLength,
-			tls:           r.TLS,
-			Proto:         r.Proto,
-			Host:          r.Host,
-			Params:        params,
-			UserAgent:     r.UserAgent(),
-			RemoteAddr:    r.RemoteAddr,
-			body:          parsedBody, // Store the unified map
-		}
+// Handle registers handler for every method in methods on pattern. All the
+// verb helpers delegate here so that body parsing, param extraction and
+// NinaRequest construction are the same for every method. An empty methods
+// slice registers the pattern for any method. The body is not read here, it
+// is parsed on the first GetBody or Bind call.
+//
+// Path params can be constrained with a type or a regular expression, like
+// {id:int}, {id:uuid} or {slug:[a-z-]+}. Requests that do not match go to
+// the next route with the same shape, or are not found. The returned Route
+// can be named to build its URL later.
+func (mux *ServeMux) Handle(methods []string, pattern string, handler Handler, middlewares []Middleware) *Route {
+	finalHandler := mux.chain(applyMiddlewares(handler, middlewares...))
+	rp := parseRoutePattern(pattern)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ninaRequest := newNinaRequest(r, &rp, nil)
+		ninaRequest.router = mux
+		res := NewResponse(w, ninaRequest)
+		if mux.MaxBodyBytes > 0 {
+			ninaRequest.LimitBody(res, mux.MaxBodyBytes)
+		}
+		finalHandler(res, ninaRequest)
+	})
+
+	if len(methods) == 0 {
+		mux.handleRoute("", rp, h)
+	}
+	for _, method := range methods {
+		mux.trackMethod(method)
+		mux.handleRoute(method, rp, h)
+		mux.methodRoutes = append(mux.methodRoutes, newMethodRoute(method, rp))
+	}
+	route := &Route{router: mux, pattern: pattern, methods: methods, rp: &rp, handler: handler, handlerName: funcName(handler), middlewares: middlewares}
+	mux.routeList = append(mux.routeList, route)
+	return route
+}
 
-		finalHandler(w, ninaRequest)
-	}))
+// HandleE is Handle for a HandlerE. Routes reports the name of h instead of
+// the E adapter.
+func (mux *ServeMux) HandleE(methods []string, pattern string, h HandlerE, middlewares []Middleware) *Route {
+	route := mux.Handle(methods, pattern, E(h), middlewares)
+	route.handlerName = funcName(h)
+	return route
 }
 
-func (mux *ServeMux) PUT(pattern string, handler Handler, middlewares []Middleware) {
-	finalHandler := applyMiddlewares(handler, middlewares...)
-	mux.ServeMux.Handle(http.MethodPut+" "+pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPut {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
+// Match registers handler for the given set of methods on pattern.
+func (mux *ServeMux) Match(methods []string, pattern string, handler Handler, middlewares []Middleware) *Route {
+	if len(methods) == 0 {
+		panic("router: Match requires at least one method")
+	}
+	return mux.Handle(methods, pattern, handler, middlewares)
+}
 
-		// Read the request body
-		bodyBytes, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, "Unable to read body", http.StatusBadRequest)
-			return
-		}
-		defer r.Body.Close()
+// ANY registers handler on pattern for every HTTP method.
+func (mux *ServeMux) ANY(pattern string, handler Handler, middlewares []Middleware) *Route {
+	return mux.Handle(nil, pattern, handler, middlewares)
+}
 
-		// Restore the body for potential reuse
-		r.Body = ioutil.NopCloser(bytes.NewReader(bodyBytes))
+func (mux *ServeMux) GET(pattern string, handler Handler, middlewares []Middleware) *Route {
+	return mux.Handle([]string{http.MethodGet}, pattern, handler, middlewares)
+}
 
-		// Parse body into a unified map
-		parsedBody := make(map[string]interface{})
-		contentType := r.Header.Get("Content-Type")
+func (mux *ServeMux) POST(pattern string, handler Handler, middlewares []Middleware) *Route {
+	return mux.Handle([]string{http.MethodPost}, pattern, handler, middlewares)
+}
 
-		switch {
-		case contentType == "application/json":
-			// Parse JSON
-			if err := json.Unmarshal(bodyBytes, &parsedBody); err != nil {
-				http.Error(w, "Invalid JSON", http.StatusBadRequest)
-				return
-			}
-		case contentType == "application/xml" || contentType == "text/xml":
-			// Parse the XML into a generic tree structure
-			var root GenericXML
-			if err := xml.Unmarshal(bodyBytes, &root); err != nil {
-				http.Error(w, "Invalid XML", http.StatusBadRequest)
-				return
-			}
+func (mux *ServeMux) PUT(pattern string, handler Handler, middlewares []Middleware) *Route {
+	return mux.Handle([]string{http.MethodPut}, pattern, handler, middlewares)
+}
 
-			// Convert the XML tree to a map
-			parsedBody = xmlToMap(root)
+func (mux *ServeMux) PATCH(pattern string, handler Handler, middlewares []Middleware) *Route {
+	return mux.Handle([]string{http.MethodPatch}, pattern, handler, middlewares)
+}
 
-		case contentType == "application/x-www-form-urlencoded":
-			// Parse form data
-			if err := r.ParseForm(); err != nil {
-				http.Error(w, "Unable to parse form data", http.StatusBadRequest)
-				return
-			}
-			for key, values := range r.PostForm {
-				// Add form data to the map (use the first value for simplicity)
-				if len(values) > 0 {
-					parsedBody[key] = values[0]
-				}
-			}
-		default:
-			// For unsupported content types, treat as raw text and try to parse
-			rawBody := string(bodyBytes)
-			parsedMap, err := parseRawBody(rawBody)
-			if err != nil {
-				http.Error(w, fmt.Sprintf("Unable to parse raw body: %v", err), http.StatusBadRequest)
-				return
-			}
-			for key, value := range parsedMap {
-				parsedBody[key] = value
-			}
-			parsedBody["rawBody"] = string(bodyBytes)
-		}
+func (mux *ServeMux) DELETE(pattern string, handler Handler, middlewares []Middleware) *Route {
+	return mux.Handle([]string{http.MethodDelete}, pattern, handler, middlewares)
+}
 
-		// Set up request parameters
-		reqParams := getReqParams(r, pattern)
-		params := &NinaParamsRequest{
-			QueryString: reqParams["queryString"],
-			UriParams:   reqParams["uriParams"],
-			Params:      reqParams["params"],
-		}
+func (mux *ServeMux) TRACE(pattern string, handler Handler, middlewares []Middleware) *Route {
+	return mux.Handle([]string{http.MethodTrace}, pattern, handler, middlewares)
+}
 
-		// Create the custom NinaRequest
-		ninaRequest := &NinaRequest{
-			Request:       r,
-			Header:        r.Header,
-			Form:          &r.Form,
-			Method:        r.Method,
-			PostForm:      &r.PostForm,
-			ctx:           r.Context(),
-			ContentLength: r.ContentLength,
-			tls:           r.TLS,
-			Proto:         r.Proto,
-			Host:          r.Host,
-			Params:        params,
-			UserAgent:     r.UserAgent(),
-			RemoteAddr:    r.RemoteAddr,
-			body:          parsedBody, // Store the unified map
-		}
+func (mux *ServeMux) OPTIONS(pattern string, handler Handler, middlewares []Middleware) *Route {
+	return mux.Handle([]string{http.MethodOptions}, pattern, handler, middlewares)
+}
 
-		finalHandler(w, ninaRequest)
-	}))
+func (mux *ServeMux) HEAD(pattern string, handler Handler, middlewares []Middleware) *Route {
+	return mux.Handle([]string{http.MethodHead}, pattern, handler, middlewares)
 }
 
-func (mux *ServeMux) DELETE(pattern string, handler Handler, middlewares []Middleware) {
-	finalHandler := applyMiddlewares(handler, middlewares...)
-	mux.ServeMux.Handle(http.MethodDelete+" "+pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodDelete {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		reqParams := getReqParams(r, pattern)
-		params := &NinaParamsRequest{
-			QueryString: reqParams["queryString"],
-			UriParams:   reqParams["uriParams"],
-			Params:      reqParams["params"],
-		}
+func (mux *ServeMux) CONNECT(pattern string, handler Handler, middlewares []Middleware) *Route {
+	return mux.Handle([]string{http.MethodConnect}, pattern, handler, middlewares)
+}
 
-		ninaRequest := &NinaRequest{
-			Request:       r,
-			Header:        r.Header,
-			Form:          &r.Form,
-			Method:        r.Method,
-			PostForm:      &r.PostForm,
-			ctx:           r.Context(),
-			ContentLength: r.ContentLength,
-			tls:           r.TLS,
-			Proto:         r.Proto,
-			Host:          r.Host,
-			Params:        params,
-			UserAgent:     r.UserAgent(),
-			RemoteAddr:    r.RemoteAddr,
+// newNinaRequest wraps r into a NinaRequest, extracting the query and the
+// path params of route, which is nil for requests that did not match one.
+func newNinaRequest(r *http.Request, route *routePattern, body interface{}) *NinaRequest {
+	params := &NinaParamsRequest{}
+	if r.URL.RawQuery != "" {
+		params.query = r.URL.Query()
+		params.QueryString = parseQueryString(params.query)
+	}
+	if route != nil && len(route.names) > 0 {
+		params.UriParams = make(map[string]string, len(route.names))
+		for _, name := range route.names {
+			params.UriParams[name] = r.PathValue(name)
 		}
-		finalHandler(w, ninaRequest)
-	}))
-}
-
-func xmlToMap(node GenericXML) map[string]interface{} {
-	result := make(map[string]interface{})
-
-	// If the node has no children, it's a leaf node
-	if len(node.Nodes) == 0 {
-		result[node.XMLName.Local] = node.Content
-		return result
-	}
-
-	// Recursively process child nodes
-	children := map[string]interface{}{}
-	for _, child := range node.Nodes {
-		childMap := xmlToMap(child)
-		for key, value := range childMap {
-			// Handle duplicate keys by appending to a slice
-			if existing, found := children[key]; found {
-				switch v := existing.(type) {
-				case []interface{}:
-					children[key] = append(v, value)
-				default:
-					children[key] = []interface{}{v, value}
-				}
-			} else {
-				children[key] = value
-			}
+	}
+	switch {
+	case params.UriParams == nil:
+		params.Params = params.QueryString
+	case params.QueryString == nil:
+		params.Params = params.UriParams
+	default:
+		params.Params = make(map[string]string, len(params.QueryString)+len(params.UriParams))
+		for key, value := range params.QueryString {
+			params.Params[key] = value
+		}
+		for key, value := range params.UriParams {
+			params.Params[key] = value
 		}
 	}
 
-	// Add the processed children to the current node
-	result[node.XMLName.Local] = children
-	return result
+	// Create the custom NinaRequest
+	nr := &NinaRequest{
+		Request:       r,
+		Header:        r.Header,
+		Form:          &r.Form,
+		Method:        r.Method,
+		PostForm:      &r.PostForm,
+		ctx:           r.Context(),
+		ContentLength: r.ContentLength,
+		tls:           r.TLS,
+		Proto:         r.Proto,
+		Host:          r.Host,
+		Params:        params,
+		UserAgent:     r.UserAgent(),
+		RemoteAddr:    r.RemoteAddr,
+		body:          body, // Store the unified map
+		bodySource:    r.Body,
+	}
+	params.request = nr
+	return nr
 }
 
-func (mux *ServeMux) TRACE(pattern string, handler Handler, middlewares []Middleware) {
-	finalHandler := applyMiddlewares(handler, middlewares...)
-	mux.ServeMux.Handle(http.MethodTrace+" "+pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodTrace {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		reqParams := getReqParams(r, pattern)
-		params := &NinaParamsRequest{
-			QueryString: reqParams["queryString"],
-			UriParams:   reqParams["uriParams"],
-			Params:      reqParams["params"],
-		}
+// Body kinds understood by the router, shared by parseBody and the binders
+const (
+	bodyRaw = iota
+	bodyDecoded
+	bodyForm
+	bodyMultipart
+)
 
-		ninaRequest := &NinaRequest{
-			Request:       r,
-			Header:        r.Header,
-			Form:          &r.Form,
-			Method:        r.Method,
-			PostForm:      &r.PostForm,
-			ctx:           r.Context(),
-			ContentLength: r.ContentLength,
-			tls:           r.TLS,
-			Proto:         r.Proto,
-			Host:          r.Host,
-			Params:        params,
-			UserAgent:     r.UserAgent(),
-			RemoteAddr:    r.RemoteAddr,
-		}
-		finalHandler(w, ninaRequest)
-	}))
+// bodyKind maps a Content-Type header to one of the body kinds above, with
+// the decoders registered on the router
+func (mux *ServeMux) bodyKind(contentType string) int {
+	switch mediaType := declaredType(contentType); {
+	case mediaType == "application/x-www-form-urlencoded":
+		return bodyForm
+	case mediaType == "multipart/form-data":
+		return bodyMultipart
+	case mux.LookupDecoder(mediaType) != nil:
+		return bodyDecoded
+	default:
+		return bodyRaw
+	}
 }
 
-func (mux *ServeMux) OPTIONS(pattern string, handler Handler, middlewares []Middleware) {
-	finalHandler := applyMiddlewares(handler, middlewares...)
-	mux.ServeMux.Handle(http.MethodOptions+" "+pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodOptions {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		reqParams := getReqParams(r, pattern)
-		params := &NinaParamsRequest{
-			QueryString: reqParams["queryString"],
-			UriParams:   reqParams["uriParams"],
-			Params:      reqParams["params"],
-		}
+// parseBody reads the request body and parses it into a unified map with the
+// Decoder the router has for the Content-Type header. The body is restored so
+// handlers can read it again and the raw bytes are returned for the binders.
+func (mux *ServeMux) parseBody(r *http.Request) (map[string]interface{}, []byte, error) {
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+	if mux.bodyKind(r.Header.Get("Content-Type")) == bodyMultipart {
+		// Uploads are left untouched so they can be streamed, see multipart.go
+		return make(map[string]interface{}), nil, nil
+	}
 
-		ninaRequest := &NinaRequest{
-			Request:       r,
-			Header:        r.Header,
-			Form:          &r.Form,
-			Method:        r.Method,
-			PostForm:      &r.PostForm,
-			ctx:           r.Context(),
-			ContentLength: r.ContentLength,
-			tls:           r.TLS,
-			Proto:         r.Proto,
-			Host:          r.Host,
-			Params:        params,
-			UserAgent:     r.UserAgent(),
-			RemoteAddr:    r.RemoteAddr,
-		}
-		finalHandler(w, ninaRequest)
-	}))
-}
+	bodyBytes, err := readBody(r)
+	if err != nil {
+		return nil, nil, err
+	}
 
-func (mux *ServeMux) HEAD(pattern string, handler Handler, middlewares []Middleware) {
-	finalHandler := applyMiddlewares(handler, middlewares...)
-	mux.ServeMux.Handle(http.MethodHead+" "+pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodHead {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
+	parsedBody := make(map[string]interface{})
+	kind := mux.bodyKind(r.Header.Get("Content-Type"))
+	if len(bodyBytes) == 0 && kind != bodyForm {
+		// Nothing to parse, GET and friends usually land here. Forms go on as
+		// a middleware may already have parsed them with ParseForm
+		return parsedBody, bodyBytes, nil
+	}
+	switch kind {
+	case bodyDecoded:
+		// Decode with the decoder registered for the media type
+		decoder, mediaType := mux.findDecoder(r.Header.Get("Content-Type"))
+		if err := decoder.Decode(bodyBytes, &parsedBody); err != nil {
+			name := decoderName(mediaType)
+			return nil, nil, withSyntaxOffset(NewHTTPError(http.StatusBadRequest, "invalid_"+name, "Invalid "+strings.ToUpper(name)).Wrap(err))
+		}
+		if parsedBody == nil {
+			// A JSON null for instance
+			parsedBody = make(map[string]interface{})
+		}
+	case bodyForm:
+		// Parse form data
+		if err := r.ParseForm(); err != nil {
+			return nil, nil, NewHTTPError(http.StatusBadRequest, "invalid_form", "Unable to parse form data").Wrap(err)
+		}
+		for key, values := range r.PostForm {
+			// Add form data to the map (use the first value for simplicity,
+			// every value stays available through Params.FormAll)
+			if len(values) > 0 {
+				parsedBody[key] = values[0]
+			}
+		}
+	default:
+		// For unsupported content types, treat as raw text and try to parse
+		rawBody := string(bodyBytes)
+		parsedMap, err := parseRawBody(rawBody)
+		if err != nil {
+			return nil, nil, withSyntaxOffset(NewHTTPError(http.StatusBadRequest, "invalid_body", "Unable to parse raw body").Wrap(err))
 		}
-		reqParams := getReqParams(r, pattern)
-		params := &NinaParamsRequest{
-			QueryString: reqParams["queryString"],
-			UriParams:   reqParams["uriParams"],
-			Params:      reqParams["params"],
+		for key, value := range parsedMap {
+			parsedBody[key] = value
 		}
+		parsedBody["rawBody"] = rawBody
+	}
 
-		ninaRequest := &NinaRequest{
-			Request:       r,
-			Header:        r.Header,
-			Form:          &r.Form,
-			Method:        r.Method,
-			PostForm:      &r.PostForm,
-			ctx:           r.Context(),
-			ContentLength: r.ContentLength,
-			tls:           r.TLS,
-			Proto:         r.Proto,
-			Host:          r.Host,
-			Params:        params,
-			UserAgent:     r.UserAgent(),
-			RemoteAddr:    r.RemoteAddr,
-		}
-		finalHandler(w, ninaRequest)
-	}))
+	return parsedBody, bodyBytes, nil
 }
 
-func (mux *ServeMux) CONNECT(pattern string, handler Handler, middlewares []Middleware) {
-	finalHandler := applyMiddlewares(handler, middlewares...)
-	mux.ServeMux.Handle(http.MethodConnect+" "+pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodConnect {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		reqParams := getReqParams(r, pattern)
-		params := &NinaParamsRequest{
-			QueryString: reqParams["queryString"],
-			UriParams:   reqParams["uriParams"],
-			Params:      reqParams["params"],
+// readBody reads the whole request body and restores it so it can be read
+// again
+func readBody(r *http.Request) ([]byte, error) {
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, NewHTTPError(http.StatusRequestEntityTooLarge, "body_too_large", "").Wrap(err)
 		}
+		return nil, NewHTTPError(http.StatusBadRequest, "unreadable_body", "Unable to read body").Wrap(err)
+	}
+	r.Body.Close()
 
-		ninaRequest := &NinaRequest{
-			Request:       r,
-			Header:        r.Header,
-			Form:          &r.Form,
-			Method:        r.Method,
-			PostForm:      &r.PostForm,
-			ctx:           r.Context(),
-			ContentLength: r.ContentLength,
-			tls:           r.TLS,
-			Proto:         r.Proto,
-			Host:          r.Host,
-			Params:        params,
-			UserAgent:     r.UserAgent(),
-			RemoteAddr:    r.RemoteAddr,
-		}
-		finalHandler(w, ninaRequest)
-	}))
+	// Restore the body for potential reuse
+	r.Body = ioutil.NopCloser(bytes.NewReader(bodyBytes))
+	return bodyBytes, nil
 }
 
-func getReqParams(r *http.Request, pattern string) map[string]map[string]string {
-	qs := parseQueryString(r)
-	uriParams := parseUriParams(r, pattern)
-
-	params := make(map[string]map[string]string)
-	params["params"] = make(map[string]string)
-	params["queryString"] = qs
-	params["uriParams"] = uriParams
-
-	for key, value := range qs {
-		params["params"][key] = value
+// withSyntaxOffset tells the client where its body is malformed when the
+// decoder knows it
+func withSyntaxOffset(e *HTTPError) *HTTPError {
+	var jsonErr *json.SyntaxError
+	var kvErr *KVSyntaxError
+	switch {
+	case errors.As(e.Err, &jsonErr):
+		e.WithExtension("offset", jsonErr.Offset)
+	case errors.As(e.Err, &kvErr):
+		e.WithExtension("offset", kvErr.Offset)
 	}
-	for key, value := range uriParams {
-		params["params"][key] = value
-	}
-
-	return params
+	return e
 }
 
-func parseQueryString(r *http.Request) map[string]string {
-	rawQS := r.URL.Query()
-	qs := make(map[string]string)
+func parseQueryString(rawQS url.Values) map[string]string {
+	qs := make(map[string]string, len(rawQS))
 	for key, values := range rawQS {
 		if len(values) > 0 {
 			qs[key] = values[0]
@@ -540,90 +540,29 @@ func parseQueryString(r *http.Request) map[string]string {
 	return qs
 }
 
+// parseRawBody parses the bodies without a registered decoder as
+// text/x-nina-kv, a lone key is accepted so plain text still parses
 func parseRawBody(rawBody string) (map[string]string, error) {
-	// Define valid separators
-	separators := []rune{',', ';', '|'}
-	result := make(map[string]string)
-
-	var keyBuilder, valueBuilder strings.Builder
-	isEscaped := false
-	isParsingKey := true
-
-	for _, char := range rawBody {
-		switch {
-		case isEscaped:
-			// Append the escaped character
-			if isParsingKey {
-				keyBuilder.WriteRune(char)
-			} else {
-				valueBuilder.WriteRune(char)
-			}
-			isEscaped = false
-		case char == '\\':
-			// Handle escape sequences
-			isEscaped = true
-		case contains(separators, char):
-			// Handle separators
-			if isParsingKey {
-				return nil, errors.New("missing key-value separator '=' in raw body")
-			}
-			// Save the current key-value pair
-			key := strings.TrimSpace(keyBuilder.String())
-			value := strings.TrimSpace(valueBuilder.String())
-			if key != "" {
-				result[key] = value
-			}
-			// Reset builders for the next pair
-			keyBuilder.Reset()
-			valueBuilder.Reset()
-			isParsingKey = true
-		case char == '=' && isParsingKey:
-			// Switch to parsing the value
-			isParsingKey = false
-		default:
-			// Append to the appropriate builder
-			if isParsingKey {
-				keyBuilder.WriteRune(char)
-			} else {
-				valueBuilder.WriteRune(char)
-			}
-		}
-	}
-
-	// Add the final key-value pair if any
-	key := strings.TrimSpace(keyBuilder.String())
-	value := strings.TrimSpace(valueBuilder.String())
-	if key != "" {
-		result[key] = value
-	}
-
-	return result, nil
+	return KVCodec{BareKeys: true}.Parse(rawBody)
 }
 
-// Helper function to check if a rune is in a slice
-func contains(slice []rune, char rune) bool {
-	for _, v := range slice {
-		if v == char {
-			return true
-		}
-	}
-	return false
+func (r *NinaRequest) SetContext(ctx context.Context) {
+	r.Request = r.Request.WithContext(ctx)
 }
 
-func parseUriParams(r *http.Request, pattern string) map[string]string {
-	re := regexp.MustCompile(`{([^}]*)}`)
-	matches := re.FindAllStringSubmatch(pattern, -1)
-
-	params := make(map[string]string)
-	for _, match := range matches {
-		params[match[1]] = r.PathValue(match[1])
+// Derive returns a copy of the request using ctx, for handlers run apart from
+// the request like TimeoutMiddleware does. The copy has its own Params, so
+// changing its context or loading its form leaves r untouched.
+func (r *NinaRequest) Derive(ctx context.Context) *NinaRequest {
+	derived := *r
+	derived.Request = r.Request.WithContext(ctx)
+	derived.ctx = ctx
+	if r.Params != nil {
+		params := *r.Params
+		params.request = &derived
+		derived.Params = &params
 	}
-
-	return params
-}
-
-func (r *NinaRequest) SetContext(ctx context.Context) {
-	r.Request = r.Request.WithContext(ctx)
+	return &derived
 }
 
 // variadic so can be any size of array
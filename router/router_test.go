@@ -1,8 +1,13 @@
 package router
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -64,3 +69,326 @@ func TestRouter(t *testing.T) {
 		})
 	}
 }
+
+func TestRouterPatchAnyAndMatch(t *testing.T) {
+	nr := NewRouter()
+
+	methodHandler := func(w http.ResponseWriter, r *NinaRequest) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(r.Method + " " + r.Params.UriParams["id"]))
+	}
+
+	nr.PATCH("/patch/{id}", methodHandler, nil)
+	nr.ANY("/any/{id}", methodHandler, nil)
+	nr.Match([]string{http.MethodPost, http.MethodPut}, "/match/{id}", methodHandler, nil)
+
+	tests := []struct {
+		method     string
+		url        string
+		wantStatus int
+		wantBody   string
+	}{
+		{"PATCH", "/patch/1", http.StatusOK, "PATCH 1"},
+		{"GET", "/patch/1", http.StatusMethodNotAllowed, ""},
+		{"GET", "/any/2", http.StatusOK, "GET 2"},
+		{"DELETE", "/any/2", http.StatusOK, "DELETE 2"},
+		{"PATCH", "/any/2", http.StatusOK, "PATCH 2"},
+		{"POST", "/match/3", http.StatusOK, "POST 3"},
+		{"PUT", "/match/3", http.StatusOK, "PUT 3"},
+		{"GET", "/match/3", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.url, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, nil)
+			rr := httptest.NewRecorder()
+
+			nr.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %v, want %v", rr.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && rr.Body.String() != tt.wantBody {
+				t.Errorf("got body %v, want %v", rr.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRouterParsesBodyForEveryMethod(t *testing.T) {
+	nr := NewRouter()
+
+	bodyHandler := func(w http.ResponseWriter, r *NinaRequest) {
+		body, err := r.GetBody()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(fmt.Sprint(body["name"])))
+	}
+
+	nr.Match([]string{http.MethodPost, http.MethodPatch, http.MethodDelete}, "/body", bodyHandler, nil)
+
+	for _, method := range []string{http.MethodPost, http.MethodPatch, http.MethodDelete} {
+		t.Run(method, func(t *testing.T) {
+			req := httptest.NewRequest(method, "/body", strings.NewReader(`{"name":"nina"}`))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+
+			nr.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Errorf("got status %v, want %v", rr.Code, http.StatusOK)
+			}
+			if rr.Body.String() != "nina" {
+				t.Errorf("got body %v, want %v", rr.Body.String(), "nina")
+			}
+		})
+	}
+}
+
+func TestUse(t *testing.T) {
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(w http.ResponseWriter, r *NinaRequest) {
+				calls = append(calls, name)
+				next(w, r)
+			}
+		}
+	}
+	okHandler := func(w http.ResponseWriter, r *NinaRequest) {
+		calls = append(calls, "handler")
+	}
+
+	nr := NewRouter()
+	nr.Use(trace("first"))
+	nr.GET("/route", okHandler, []Middleware{trace("route")})
+	nr.GROUP("/group", []Middleware{trace("group")}, nil).GET("/route", okHandler, nil)
+	nr.POST("/body", okHandler, nil)
+
+	sub := NewRouter()
+	sub.Use(trace("sub"))
+	sub.GET("/route", okHandler, nil)
+	nr.Mount("/sub", sub)
+
+	// Added after the routes, still applies to all of them
+	nr.Use(trace("second"))
+
+	tests := []struct {
+		method    string
+		url       string
+		body      string
+		wantCalls string
+	}{
+		{"GET", "/route", "", "first,second,route,handler"},
+		{"GET", "/group/route", "", "first,second,group,handler"},
+		{"GET", "/sub/route", "", "first,second,sub,handler"},
+		{"GET", "/missing", "", "first,second"},
+		{"DELETE", "/route", "", "first,second"},
+		// The body is parsed lazily, the handler runs and never reads it
+		{"POST", "/body", "{", "first,second,handler"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.url, func(t *testing.T) {
+			calls = nil
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			nr.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got := strings.Join(calls, ","); got != tt.wantCalls {
+				t.Errorf("got calls %v, want %v", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestUseComposesOnce(t *testing.T) {
+	built := 0
+	counting := func(next Handler) Handler {
+		built++
+		return next
+	}
+	okHandler := func(w http.ResponseWriter, r *NinaRequest) {}
+
+	nr := NewRouter()
+	nr.Use(counting)
+	nr.GET("/route", okHandler, nil)
+
+	tests := []struct {
+		name      string
+		url       string
+		wantBuilt int
+	}{
+		{"First request composes", "/route", 1},
+		{"Next request reuses", "/route", 1},
+		{"Fallback composes its own", "/missing", 2},
+		{"Then reuses", "/missing", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if built != tt.wantBuilt {
+				t.Errorf("got %d compositions, want %d", built, tt.wantBuilt)
+			}
+		})
+	}
+}
+
+func TestLazyBodyParsing(t *testing.T) {
+	nr := NewRouter()
+	nr.MaxBodyBytes = 32
+
+	nr.POST("/ignore", func(w http.ResponseWriter, r *NinaRequest) {
+		w.Write([]byte("ignored"))
+	}, nil)
+	nr.POST("/parse", E(func(w http.ResponseWriter, r *NinaRequest) error {
+		first, err := r.GetBody()
+		if err != nil {
+			return err
+		}
+		var dst struct {
+			Name string `json:"name"`
+		}
+		if err := r.Bind(&dst); err != nil {
+			return err
+		}
+		second, _ := r.GetBody()
+		return r.Response(w).Text(http.StatusOK, fmt.Sprintf("%v|%s|%v", first["name"], dst.Name, second["name"]))
+	}), nil)
+	nr.POST("/raw", E(func(w http.ResponseWriter, r *NinaRequest) error {
+		raw, err := r.RawBody()
+		if err != nil {
+			return err
+		}
+		body, err := r.GetBody()
+		if err != nil {
+			return err
+		}
+		return r.Response(w).Text(http.StatusOK, fmt.Sprintf("%s|%v", raw, body["name"]))
+	}), nil)
+
+	tests := []struct {
+		name       string
+		url        string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"Unread invalid body", "/ignore", "{", http.StatusOK, "ignored"},
+		{"Unread large body", "/ignore", strings.Repeat("x", 64), http.StatusOK, "ignored"},
+		{"Parsed once", "/parse", `{"name":"nina"}`, http.StatusOK, "nina|nina|nina"},
+		{"Invalid body", "/parse", "{", http.StatusBadRequest, ""},
+		{"Large body", "/parse", `{"name":"` + strings.Repeat("x", 64) + `"}`, http.StatusRequestEntityTooLarge, ""},
+		{"Raw then parsed", "/raw", `{"name":"nina"}`, http.StatusOK, `{"name":"nina"}|nina`},
+		{"Large raw body", "/raw", `{"name":"` + strings.Repeat("x", 64) + `"}`, http.StatusRequestEntityTooLarge, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+
+			nr.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %v, want %v", rr.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && rr.Body.String() != tt.wantBody {
+				t.Errorf("got body %v, want %v", rr.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestPeerCertificates(t *testing.T) {
+	leaf := &x509.Certificate{Subject: pkix.Name{CommonName: "leaf"}}
+	root := &x509.Certificate{Subject: pkix.Name{CommonName: "root"}}
+
+	tests := []struct {
+		name           string
+		state          *tls.ConnectionState
+		wantVerified   string
+		wantUnverified string
+	}{
+		{"Plain HTTP", nil, "", ""},
+		{"No client certificate", &tls.ConnectionState{}, "", ""},
+		{"Unverified chain", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}}, "", "leaf"},
+		{"Verified chain", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}, VerifiedChains: [][]*x509.Certificate{{leaf, root}}}, "leaf,root", "leaf"},
+	}
+
+	names := func(certs []*x509.Certificate) string {
+		var names []string
+		for _, cert := range certs {
+			names = append(names, cert.Subject.CommonName)
+		}
+		return strings.Join(names, ",")
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nr := NewRouter()
+			nr.GET("/whoami", func(w http.ResponseWriter, r *NinaRequest) {
+				if got := names(r.PeerCertificates()); got != tt.wantVerified {
+					t.Errorf("got verified chain %q, want %q", got, tt.wantVerified)
+				}
+				if got := names(r.UnverifiedPeerCertificates()); got != tt.wantUnverified {
+					t.Errorf("got unverified chain %q, want %q", got, tt.wantUnverified)
+				}
+			}, nil)
+
+			req := httptest.NewRequest("GET", "/whoami", nil)
+			req.TLS = tt.state
+			nr.ServeHTTP(httptest.NewRecorder(), req)
+		})
+	}
+}
+
+func benchmarkRoute(b *testing.B, register func(nr *ServeMux), target string) {
+	nr := NewRouter()
+	register(nr)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		// One request per goroutine, the mux writes the matched pattern into it
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		w := httptest.NewRecorder()
+		for pb.Next() {
+			w.Body.Reset()
+			nr.ServeHTTP(w, req)
+		}
+	})
+}
+
+func BenchmarkStaticRoute(b *testing.B) {
+	benchmarkRoute(b, func(nr *ServeMux) {
+		nr.GET("/health", func(w http.ResponseWriter, r *NinaRequest) {}, nil)
+	}, "/health")
+}
+
+func BenchmarkParamRoute(b *testing.B) {
+	benchmarkRoute(b, func(nr *ServeMux) {
+		nr.GET("/users/{id}/posts/{post}", func(w http.ResponseWriter, r *NinaRequest) {
+			_ = r.Params.UriParams["post"]
+		}, nil)
+	}, "/users/42/posts/7")
+}
+
+func BenchmarkConstrainedRoute(b *testing.B) {
+	benchmarkRoute(b, func(nr *ServeMux) {
+		nr.GET("/users/{id:int}", func(w http.ResponseWriter, r *NinaRequest) {}, nil)
+		nr.GET("/users/{slug:[a-z-]+}", func(w http.ResponseWriter, r *NinaRequest) {}, nil)
+	}, "/users/nina-rossi")
+}
+
+func BenchmarkQueryRoute(b *testing.B) {
+	benchmarkRoute(b, func(nr *ServeMux) {
+		nr.GET("/search", func(w http.ResponseWriter, r *NinaRequest) {
+			_ = r.Params.QueryString["q"]
+		}, nil)
+	}, "/search?q=nina&page=2")
+}
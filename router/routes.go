@@ -0,0 +1,136 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// RouteInfo describes a registered route, see Routes.
+type RouteInfo struct {
+	Method      string   `json:"method"` // ANY for routes accepting every method
+	Pattern     string   `json:"pattern"`
+	Name        string   `json:"name,omitempty"`
+	Handler     string   `json:"handler"`
+	Middlewares []string `json:"middlewares"`
+}
+
+// Routes lists every route of the router, one per method, sorted by pattern
+// then method. Patterns include the group prefixes and the mount prefixes of
+// the routes of mounted routers. Middlewares are listed in the order they
+// run, router level ones first.
+func (mux *ServeMux) Routes() []RouteInfo {
+	routes := mux.routeInfos("", nil)
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Pattern != routes[j].Pattern {
+			return routes[i].Pattern < routes[j].Pattern
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+func (mux *ServeMux) routeInfos(prefix string, parentMiddlewares []string) []RouteInfo {
+	chain := append(append([]string{}, parentMiddlewares...), middlewareNames(mux.middlewares)...)
+
+	var routes []RouteInfo
+	for _, route := range mux.routeList {
+		middlewares := append(append([]string{}, chain...), middlewareNames(route.middlewares)...)
+		methods := route.methods
+		if len(methods) == 0 {
+			methods = []string{"ANY"}
+		}
+		for _, method := range methods {
+			routes = append(routes, RouteInfo{
+				Method:      method,
+				Pattern:     prefix + route.pattern,
+				Name:        route.name,
+				Handler:     route.handlerName,
+				Middlewares: middlewares,
+			})
+		}
+	}
+	for _, mount := range mux.mounts {
+		routes = append(routes, mount.sub.routeInfos(prefix+mount.prefix, chain)...)
+	}
+	return routes
+}
+
+// RoutesHandler serves the route table as JSON or as a text table, following
+// the Accept header or the format query param (json or text). It exposes the
+// whole API surface, register it behind an authentication middleware:
+//
+//	mux.GET("/debug/routes", mux.RoutesHandler(), []router.Middleware{auth})
+func (mux *ServeMux) RoutesHandler() Handler {
+	return func(w http.ResponseWriter, r *NinaRequest) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "json"
+			if negotiateMediaType(r.Header.Get("Accept"), []string{"application/json", "text/plain"}) == "text/plain" {
+				format = "text"
+			}
+		}
+
+		res := r.Response(w)
+		routes := mux.Routes()
+		switch format {
+		case "json":
+			if err := res.JSON(http.StatusOK, routes); err != nil {
+				r.Error(w, err)
+			}
+		case "text":
+			res.Text(http.StatusOK, routesTable(routes))
+		default:
+			r.Error(w, NewHTTPError(http.StatusBadRequest, "invalid_format", "format must be json or text"))
+		}
+	}
+}
+
+func routesTable(routes []RouteInfo) string {
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATTERN\tNAME\tHANDLER\tMIDDLEWARES")
+	for _, route := range routes {
+		name := route.Name
+		if name == "" {
+			name = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", route.Method, route.Pattern, name, route.Handler, strings.Join(route.Middlewares, ", "))
+	}
+	tw.Flush()
+	return b.String()
+}
+
+func middlewareNames(middlewares []Middleware) []string {
+	names := make([]string, 0, len(middlewares))
+	for _, middleware := range middlewares {
+		// Middlewares are usually closures built by a constructor, name the constructor
+		names = append(names, closureSuffix.ReplaceAllString(funcName(middleware), ""))
+	}
+	return names
+}
+
+// closureSuffix matches the suffix the runtime gives to anonymous functions
+var closureSuffix = regexp.MustCompile(`(\.func\d+)+$`)
+
+// funcName returns the package qualified name of fn, like router.NotFound
+func funcName(fn interface{}) string {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return ""
+	}
+	f := runtime.FuncForPC(v.Pointer())
+	if f == nil {
+		return "unknown"
+	}
+	name := f.Name()
+	if idx := strings.LastIndex(name, "/"); idx != -1 {
+		name = name[idx+1:]
+	}
+	return strings.TrimSuffix(name, "-fm")
+}
@@ -0,0 +1,143 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func listUsers(w http.ResponseWriter, r *NinaRequest) {}
+
+func showInvoice(w http.ResponseWriter, r *NinaRequest) {}
+
+func tagMiddleware(tag string) Middleware {
+	return func(next Handler) Handler {
+		return func(w http.ResponseWriter, r *NinaRequest) {
+			next(w, r)
+		}
+	}
+}
+
+func authMiddleware(next Handler) Handler {
+	return next
+}
+
+func TestRoutes(t *testing.T) {
+	nr := NewRouter()
+	nr.Use(tagMiddleware("root"))
+	nr.Match([]string{http.MethodGet, http.MethodHead}, "/users", listUsers, nil).Name("user.list")
+	admin := nr.GROUP("/admin", []Middleware{authMiddleware}, nil)
+	admin.DELETE("/users/{id:int}", listUsers, []Middleware{tagMiddleware("audit")})
+	nr.ANY("/ping", func(w http.ResponseWriter, r *NinaRequest) {}, nil)
+
+	billing := NewRouter()
+	billing.GET("/invoices/{id}", showInvoice, nil).Name("invoice.show")
+	nr.Mount("/billing", billing)
+
+	want := []RouteInfo{
+		{"DELETE", "/admin/users/{id:int}", "", "router.listUsers", []string{"router.tagMiddleware", "router.authMiddleware", "router.tagMiddleware"}},
+		{"GET", "/billing/invoices/{id}", "invoice.show", "router.showInvoice", []string{"router.tagMiddleware"}},
+		{"ANY", "/ping", "", "router.TestRoutes.func1", []string{"router.tagMiddleware"}},
+		{"GET", "/users", "user.list", "router.listUsers", []string{"router.tagMiddleware"}},
+		{"HEAD", "/users", "user.list", "router.listUsers", []string{"router.tagMiddleware"}},
+	}
+	if got := nr.Routes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func createInvoice(w http.ResponseWriter, r *NinaRequest) error { return nil }
+
+func deleteInvoice(w http.ResponseWriter, r *NinaRequest) error { return nil }
+
+func TestRoutesErrorHandlers(t *testing.T) {
+	nr := NewRouter()
+	nr.HandleE([]string{http.MethodPost}, "/invoices", createInvoice, nil)
+	nr.GROUP("/invoices", nil, nil).HandleE([]string{http.MethodDelete}, "/{id}", deleteInvoice, nil)
+	nr.GET("/invoices", listUsers, nil)
+	nr.PUT("/invoices/{id}", E(createInvoice), nil)
+
+	tests := []struct {
+		method      string
+		wantHandler string
+	}{
+		{http.MethodGet, "router.listUsers"},
+		{http.MethodPost, "router.createInvoice"},
+		{http.MethodDelete, "router.deleteInvoice"},
+		{http.MethodPut, "router.HandlerE.ServeHTTP"},
+	}
+
+	routes := nr.Routes()
+	for i, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			if routes[i].Method != tt.method || routes[i].Handler != tt.wantHandler {
+				t.Errorf("got %v %v, want %v %v", routes[i].Method, routes[i].Handler, tt.method, tt.wantHandler)
+			}
+		})
+	}
+
+	// The adapters still serve the requests
+	rr := httptest.NewRecorder()
+	nr.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/invoices", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("got status %v, want %v", rr.Code, http.StatusOK)
+	}
+}
+
+func TestRoutesHandler(t *testing.T) {
+	nr := NewRouter()
+	nr.GET("/users", listUsers, nil).Name("user.list")
+	nr.GET("/debug/routes", nr.RoutesHandler(), nil)
+
+	tests := []struct {
+		name            string
+		url             string
+		accept          string
+		wantStatus      int
+		wantContentType string
+	}{
+		{"Default JSON", "/debug/routes", "", http.StatusOK, "application/json; charset=utf-8"},
+		{"Accept text", "/debug/routes", "text/plain", http.StatusOK, "text/plain; charset=utf-8"},
+		{"Format query", "/debug/routes?format=text", "application/json", http.StatusOK, "text/plain; charset=utf-8"},
+		{"Invalid format", "/debug/routes?format=yaml", "", http.StatusBadRequest, ProblemContentType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+
+			nr.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %v, want %v", rr.Code, tt.wantStatus)
+			}
+			if got := rr.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("got content type %v, want %v", got, tt.wantContentType)
+			}
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	nr.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/routes", nil))
+	var routes []RouteInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &routes); err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 2 || routes[1].Name != "user.list" {
+		t.Errorf("got %+v", routes)
+	}
+
+	rr = httptest.NewRecorder()
+	nr.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/routes?format=text", nil))
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "METHOD") || !strings.Contains(lines[2], "user.list") {
+		t.Errorf("got table %q", rr.Body.String())
+	}
+}
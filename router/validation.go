@@ -0,0 +1,308 @@
+package router
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldError describes a single failed validation rule.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors lists every field that failed validation.
+type ValidationErrors []*FieldError
+
+func (ve ValidationErrors) Error() string {
+	messages := make([]string, len(ve))
+	for i, e := range ve {
+		messages[i] = e.Error()
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+var (
+	uuidRegex     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	alphaRegex    = regexp.MustCompile(`^[a-zA-Z]+$`)
+	alphanumRegex = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+	regexCache    sync.Map // pattern -> *regexp.Regexp
+)
+
+// Validate checks every field of the struct pointed by v against its
+// validate:"..." tag and returns ValidationErrors listing all the failures.
+//
+// Rules are comma separated: required, omitempty, min=N, max=N, len=N, eq=X,
+// ne=X, gt=N, gte=N, lt=N, lte=N, oneof=a b c, email, url, uuid, alpha,
+// alphanum, numeric and regex=PATTERN. On strings, slices and maps
+// min/max/len compare the length, on numbers the value. regex must be the
+// last rule of the tag as it takes the rest of it, commas included. Nested
+// structs are validated too and reported as parent.child.
+//
+// Rules apply to zero values as well, omitempty skips them when the field is
+// empty. Nil pointers are optional and only checked by required.
+func Validate(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return fmt.Errorf("validate: nil %s", rv.Type())
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("validate: expected a struct, got %s", rv.Kind())
+	}
+
+	var errs ValidationErrors
+	validateStruct(rv, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Validate binds the request into dst and validates it, storing dst in
+// Validated when everything passes.
+func (nr *NinaRequest) Validate(dst interface{}) error {
+	if err := nr.Bind(dst); err != nil {
+		return err
+	}
+	if err := Validate(dst); err != nil {
+		return err
+	}
+	nr.Validated = dst
+	return nil
+}
+
+func validateStruct(rv reflect.Value, prefix string, errs *ValidationErrors) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fv := rv.Field(i)
+		name := prefix + fieldName(field)
+
+		if tag := field.Tag.Get("validate"); tag != "" && tag != "-" {
+			validateField(fv, name, tag, errs)
+		}
+
+		// Walk into nested structs
+		nested := fv
+		if nested.Kind() == reflect.Ptr {
+			if nested.IsNil() {
+				continue
+			}
+			nested = nested.Elem()
+		}
+		if nested.Kind() == reflect.Struct && nested.Type() != timeType {
+			if field.Anonymous {
+				validateStruct(nested, prefix, errs)
+			} else {
+				validateStruct(nested, name+".", errs)
+			}
+		}
+	}
+}
+
+// fieldName reports the name a client would recognise for the field, the
+// first binding tag found or the Go name otherwise
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", tagForm, tagQuery, tagURI, tagHeader, "xml"} {
+		name := field.Tag.Get(tag)
+		if idx := strings.Index(name, ","); idx != -1 {
+			name = name[:idx]
+		}
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+func validateField(fv reflect.Value, name, tag string, errs *ValidationErrors) {
+	// Unset optional pointers are only checked by required
+	isNil := fv.Kind() == reflect.Ptr && fv.IsNil()
+	if fv.Kind() == reflect.Ptr && !isNil {
+		fv = fv.Elem()
+	}
+	empty := isNil || fv.IsZero()
+
+	rules := splitRules(tag)
+	for _, rule := range rules {
+		if rule == "required" && empty {
+			*errs = append(*errs, &FieldError{Field: name, Rule: rule, Message: "is required"})
+			return
+		}
+		if rule == "omitempty" && empty {
+			return
+		}
+	}
+	if isNil {
+		return
+	}
+
+	for _, rule := range rules {
+		ruleName, param := rule, ""
+		if idx := strings.Index(rule, "="); idx != -1 {
+			ruleName, param = rule[:idx], rule[idx+1:]
+		}
+		if ruleName == "required" || ruleName == "omitempty" {
+			continue
+		}
+
+		if message, ok := checkRule(fv, ruleName, param); !ok {
+			*errs = append(*errs, &FieldError{Field: name, Rule: ruleName, Param: param, Message: message})
+		}
+	}
+}
+
+// splitRules splits a validate tag on commas, keeping regex=... whole
+func splitRules(tag string) []string {
+	var rules []string
+	for tag != "" {
+		if strings.HasPrefix(tag, "regex=") {
+			return append(rules, tag)
+		}
+		idx := strings.Index(tag, ",")
+		if idx == -1 {
+			return append(rules, strings.TrimSpace(tag))
+		}
+		if rule := strings.TrimSpace(tag[:idx]); rule != "" {
+			rules = append(rules, rule)
+		}
+		tag = strings.TrimSpace(tag[idx+1:])
+	}
+	return rules
+}
+
+// checkRule runs one rule against fv and returns the failure message
+func checkRule(fv reflect.Value, rule, param string) (string, bool) {
+	switch rule {
+	case "min", "max", "len", "gt", "gte", "lt", "lte":
+		return checkBound(fv, rule, param)
+	case "eq":
+		return "must be equal to " + param, fmt.Sprint(fv.Interface()) == param
+	case "ne":
+		return "must not be equal to " + param, fmt.Sprint(fv.Interface()) != param
+	case "oneof":
+		value := fmt.Sprint(fv.Interface())
+		for _, option := range strings.Fields(param) {
+			if value == option {
+				return "", true
+			}
+		}
+		return "must be one of [" + param + "]", false
+	case "email":
+		s := fv.String()
+		addr, err := mail.ParseAddress(s)
+		return "must be a valid email address", fv.Kind() == reflect.String && err == nil && addr.Address == s
+	case "url":
+		u, err := url.ParseRequestURI(fv.String())
+		return "must be a valid URL", fv.Kind() == reflect.String && err == nil && u.Scheme != "" && u.Host != ""
+	case "uuid":
+		return "must be a valid UUID", fv.Kind() == reflect.String && uuidRegex.MatchString(fv.String())
+	case "alpha":
+		return "must contain only letters", fv.Kind() == reflect.String && alphaRegex.MatchString(fv.String())
+	case "alphanum":
+		return "must contain only letters and digits", fv.Kind() == reflect.String && alphanumRegex.MatchString(fv.String())
+	case "numeric":
+		_, err := strconv.ParseFloat(fv.String(), 64)
+		return "must be numeric", fv.Kind() == reflect.String && err == nil
+	case "regex":
+		re, err := compileRegex(param)
+		if err != nil {
+			return "has an invalid pattern: " + err.Error(), false
+		}
+		return "must match " + param, fv.Kind() == reflect.String && re.MatchString(fv.String())
+	default:
+		return "unknown validation rule " + rule, false
+	}
+}
+
+// checkBound compares lengths for strings, slices and maps and values for numbers
+func checkBound(fv reflect.Value, rule, param string) (string, bool) {
+	if fv.Type() == durationType {
+		return checkDuration(time.Duration(fv.Int()), rule, param)
+	}
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return "has an invalid " + rule + " parameter", false
+	}
+
+	var actual float64
+	unit := ""
+	switch fv.Kind() {
+	case reflect.String:
+		actual, unit = float64(utf8.RuneCountInString(fv.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		actual, unit = float64(fv.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(fv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		actual = float64(fv.Uint())
+	case reflect.Float32, reflect.Float64:
+		actual = fv.Float()
+	default:
+		return "cannot apply " + rule + " to " + fv.Type().String(), false
+	}
+
+	switch rule {
+	case "min", "gte":
+		return "must be at least " + param + unit, actual >= limit
+	case "max", "lte":
+		return "must be at most " + param + unit, actual <= limit
+	case "gt":
+		return "must be greater than " + param + unit, actual > limit
+	case "lt":
+		return "must be less than " + param + unit, actual < limit
+	default: // len
+		return "must be exactly " + param + unit, actual == limit
+	}
+}
+
+func checkDuration(d time.Duration, rule, param string) (string, bool) {
+	limit, err := time.ParseDuration(param)
+	if err != nil {
+		return "has an invalid " + rule + " parameter", false
+	}
+	switch rule {
+	case "min", "gte":
+		return "must be at least " + param, d >= limit
+	case "max", "lte":
+		return "must be at most " + param, d <= limit
+	case "gt":
+		return "must be greater than " + param, d > limit
+	case "lt":
+		return "must be less than " + param, d < limit
+	default:
+		return "must be exactly " + param, d == limit
+	}
+}
+
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexCache.Store(pattern, re)
+	return re, nil
+}
@@ -0,0 +1,131 @@
+package router
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type validationProfile struct {
+	Website string `json:"website" validate:"omitempty,url"`
+}
+
+type validationUser struct {
+	ID       string            `json:"id" validate:"required,uuid"`
+	Name     string            `json:"name" validate:"required,min=3,max=8"`
+	Email    string            `json:"email" validate:"required,email"`
+	Plan     string            `json:"plan" validate:"oneof=free pro"`
+	Code     string            `json:"code" validate:"len=4,numeric"`
+	Slug     string            `json:"slug" validate:"regex=^[a-z]+(-[a-z]+){0,2}$"`
+	Age      int               `json:"age" validate:"gte=18,lt=130"`
+	Tags     []string          `json:"tags" validate:"max=2"`
+	Retry    time.Duration     `json:"retry" validate:"max=1m"`
+	Nickname *string           `json:"nickname" validate:"min=2"`
+	Profile  validationProfile `json:"profile"`
+}
+
+func validUser() validationUser {
+	return validationUser{
+		ID:    "7f0b1c8e-2d4a-4f7e-9b1a-0c5d6e7f8a9b",
+		Name:  "nina",
+		Email: "nina@example.com",
+		Plan:  "pro",
+		Code:  "1234",
+		Slug:  "hello-world",
+		Age:   30,
+		Tags:  []string{"a"},
+		Retry: time.Second,
+	}
+}
+
+func TestValidate(t *testing.T) {
+	short := "x"
+
+	tests := []struct {
+		name       string
+		mutate     func(u *validationUser)
+		wantFields []string
+	}{
+		{"Valid", func(u *validationUser) {}, nil},
+		{"Missing required", func(u *validationUser) { u.ID, u.Name, u.Email = "", "", "" }, []string{"id", "name", "email"}},
+		{"Bad formats", func(u *validationUser) { u.ID, u.Email = "not-a-uuid", "nina@" }, []string{"id", "email"}},
+		{"Length bounds", func(u *validationUser) { u.Name, u.Code = "ni", "12345" }, []string{"name", "code"}},
+		{"Numeric bounds", func(u *validationUser) { u.Age = 17 }, []string{"age"}},
+		{"Oneof and regex", func(u *validationUser) { u.Plan, u.Slug = "gold", "Hello_World" }, []string{"plan", "slug"}},
+		{"Slice and duration", func(u *validationUser) { u.Tags, u.Retry = []string{"a", "b", "c"}, time.Hour }, []string{"tags", "retry"}},
+		{"Optional pointer set", func(u *validationUser) { u.Nickname = &short }, []string{"nickname"}},
+		{"Nested struct", func(u *validationUser) { u.Profile.Website = "nope" }, []string{"profile.website"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := validUser()
+			tt.mutate(&u)
+
+			err := Validate(&u)
+			if tt.wantFields == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var errs ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("got %v, want ValidationErrors", err)
+			}
+			if len(errs) != len(tt.wantFields) {
+				t.Fatalf("got %v, want failures on %v", errs, tt.wantFields)
+			}
+			for i, field := range tt.wantFields {
+				if errs[i].Field != field {
+					t.Errorf("got failing field %v, want %v", errs[i].Field, field)
+				}
+			}
+		})
+	}
+
+	if err := Validate("not a struct"); err == nil {
+		t.Error("expected an error when validating a non struct")
+	}
+}
+
+func TestValidateZeroValues(t *testing.T) {
+	type order struct {
+		Qty  int    `json:"qty" validate:"gt=0"`
+		Name string `json:"name" validate:"min=3,required"`
+		Note string `json:"note" validate:"omitempty,min=3"`
+	}
+
+	tests := []struct {
+		name      string
+		order     order
+		wantRules []string
+	}{
+		{"Valid", order{Qty: 1, Name: "nina"}, nil},
+		{"Zero value checked", order{Qty: 0, Name: "nina"}, []string{"gt"}},
+		{"Required after other rules", order{Qty: 1}, []string{"required"}},
+		{"Omitempty set value checked", order{Qty: 1, Name: "nina", Note: "ok"}, []string{"min"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&tt.order)
+			var errs ValidationErrors
+			if tt.wantRules == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &errs) || len(errs) != len(tt.wantRules) {
+				t.Fatalf("got %v, want failures %v", err, tt.wantRules)
+			}
+			for i, rule := range tt.wantRules {
+				if errs[i].Rule != rule {
+					t.Errorf("got rule %v, want %v", errs[i].Rule, rule)
+				}
+			}
+		})
+	}
+}
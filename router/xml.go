@@ -0,0 +1,158 @@
+package router
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// XMLOptions tunes how XMLToMap maps an XML document. The zero value gives
+// the same map as the default application/xml decoder.
+type XMLOptions struct {
+	// Lossless keeps the attributes as "@name" keys, the namespace prefixes
+	// of the names ("soap:Body", "@xmlns:soap") and the text of elements that
+	// also have attributes or children under "#text".
+	Lossless bool
+	// AlwaysArray makes every child element a slice, even when it appears
+	// once, so the shape of the map does not depend on the payload.
+	AlwaysArray bool
+}
+
+// XMLDecoder returns an XML Decoder mapping bodies with opts, register it to
+// replace the default one:
+//
+//	mux.RegisterDecoder("application/xml", router.XMLDecoder(router.XMLOptions{Lossless: true}))
+//
+// Bind keeps using encoding/xml, so structs can still bind attributes with
+// the xml:"name,attr" tags.
+func XMLDecoder(opts XMLOptions) Decoder {
+	return DecoderFunc(func(data []byte, v interface{}) error {
+		m, ok := v.(*map[string]interface{})
+		if !ok {
+			return xml.Unmarshal(data, v)
+		}
+		mapped, err := XMLToMap(data, opts)
+		if err != nil {
+			return err
+		}
+		*m = mapped
+		return nil
+	})
+}
+
+// XMLToMap maps an XML document into a map keyed by its root element. Leaf
+// elements become their text, other elements a map of their children, with
+// repeated children collected into a slice.
+func XMLToMap(data []byte, opts XMLOptions) (map[string]interface{}, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	var root *xmlNode
+	var stack []*xmlNode
+	for {
+		var token xml.Token
+		var err error
+		if opts.Lossless {
+			// Raw tokens keep the prefixes as written by the client
+			token, err = decoder.RawToken()
+		} else {
+			token, err = decoder.Token()
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: xmlName(t.Name, opts), attrs: t.Attr}
+			switch {
+			case len(stack) > 0:
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, node)
+			case root != nil:
+				return nil, errors.New("xml: multiple root elements")
+			default:
+				root = node
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			// RawToken does not check that the elements are balanced
+			if len(stack) == 0 || stack[len(stack)-1].name != xmlName(t.Name, opts) {
+				return nil, fmt.Errorf("xml: unexpected end element </%s>", xmlName(t.Name, opts))
+			}
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, errors.New("xml: no root element")
+	}
+	if len(stack) > 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return map[string]interface{}{root.name: root.value(opts)}, nil
+}
+
+type xmlNode struct {
+	name     string
+	attrs    []xml.Attr
+	text     strings.Builder
+	children []*xmlNode
+}
+
+func (n *xmlNode) value(opts XMLOptions) interface{} {
+	hasAttrs := opts.Lossless && len(n.attrs) > 0
+	if len(n.children) == 0 && !hasAttrs {
+		return n.text.String()
+	}
+
+	result := make(map[string]interface{})
+	if opts.Lossless {
+		for _, attr := range n.attrs {
+			result["@"+xmlName(attr.Name, opts)] = attr.Value
+		}
+		text := n.text.String()
+		if len(n.children) > 0 {
+			// Mixed content, drop the indentation between the children
+			text = strings.TrimSpace(text)
+		}
+		if text != "" {
+			result["#text"] = text
+		}
+	}
+
+	for _, child := range n.children {
+		value := child.value(opts)
+		existing, found := result[child.name]
+		switch {
+		case opts.AlwaysArray && !found:
+			result[child.name] = []interface{}{value}
+		case !found:
+			result[child.name] = value
+		default:
+			if values, ok := existing.([]interface{}); ok {
+				result[child.name] = append(values, value)
+			} else {
+				result[child.name] = []interface{}{existing, value}
+			}
+		}
+	}
+	return result
+}
+
+// xmlName is the key of an element or attribute, with its prefix in lossless mode
+func xmlName(name xml.Name, opts XMLOptions) string {
+	if opts.Lossless && name.Space != "" {
+		return name.Space + ":" + name.Local
+	}
+	return name.Local
+}
@@ -0,0 +1,113 @@
+package router
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const soapOrder = `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+	<soap:Body>
+		<order id="42" currency="EUR">
+			Rush delivery
+			<item sku="a1">Pen</item>
+			<item sku="b2">Ink</item>
+			<note>fragile</note>
+		</order>
+	</soap:Body>
+</soap:Envelope>`
+
+func TestXMLToMap(t *testing.T) {
+	tests := []struct {
+		name string
+		opts XMLOptions
+		xml  string
+		want string
+	}{
+		{"Default", XMLOptions{}, `<order id="42"><item>Pen</item><item>Ink</item><note>fragile</note></order>`,
+			`{"order":{"item":["Pen","Ink"],"note":"fragile"}}`},
+		{"Always array", XMLOptions{AlwaysArray: true}, `<order><item>Pen</item><note>fragile</note></order>`,
+			`{"order":{"item":["Pen"],"note":["fragile"]}}`},
+		{"Lossless leaf with attribute", XMLOptions{Lossless: true}, `<price currency="EUR">9.90</price>`,
+			`{"price":{"#text":"9.90","@currency":"EUR"}}`},
+		{"Lossless", XMLOptions{Lossless: true}, soapOrder,
+			`{"soap:Envelope":{"@xmlns:soap":"http://schemas.xmlsoap.org/soap/envelope/","soap:Body":{"order":{"#text":"Rush delivery","@currency":"EUR","@id":"42","item":[{"#text":"Pen","@sku":"a1"},{"#text":"Ink","@sku":"b2"}],"note":"fragile"}}}}`},
+		{"Lossless always array", XMLOptions{Lossless: true, AlwaysArray: true}, `<a:list xmlns:a="urn:a"><a:item>1</a:item></a:list>`,
+			`{"a:list":{"@xmlns:a":"urn:a","a:item":["1"]}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := XMLToMap([]byte(tt.xml), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, _ := json.Marshal(got)
+			if string(b) != tt.want {
+				t.Errorf("got %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
+
+func TestXMLToMapErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+	}{
+		{"Unbalanced", `<a:order xmlns:a="urn:a"><item></a:order></item>`},
+		{"Unclosed", `<order><item>Pen</item>`},
+		{"Two roots", `<a/><b/>`},
+		{"Empty", ``},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := XMLToMap([]byte(tt.xml), XMLOptions{Lossless: true}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestXMLDecoder(t *testing.T) {
+	type item struct {
+		SKU  string `xml:"sku,attr"`
+		Name string `xml:",chardata"`
+	}
+	type envelope struct {
+		XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
+		Order   struct {
+			ID    int    `xml:"id,attr"`
+			Items []item `xml:"item"`
+		} `xml:"Body>order"`
+	}
+
+	nr := NewRouter()
+	nr.RegisterDecoder("application/soap+xml", XMLDecoder(XMLOptions{Lossless: true}))
+	nr.POST("/orders", E(func(w http.ResponseWriter, r *NinaRequest) error {
+		body, err := r.GetBody()
+		if err != nil {
+			return err
+		}
+		var env envelope
+		if err := r.Bind(&env); err != nil {
+			return err
+		}
+		order := body["soap:Envelope"].(map[string]interface{})["soap:Body"].(map[string]interface{})["order"].(map[string]interface{})
+		return r.Response(w).Text(http.StatusOK, fmt.Sprintf("%v|%d|%v", order["@id"], env.Order.ID, env.Order.Items))
+	}), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(soapOrder))
+	req.Header.Set("Content-Type", "application/soap+xml; charset=utf-8")
+	rr := httptest.NewRecorder()
+	nr.ServeHTTP(rr, req)
+
+	if want := "42|42|[{a1 Pen} {b2 Ink}]"; rr.Body.String() != want {
+		t.Errorf("got %v, want %v", rr.Body.String(), want)
+	}
+}
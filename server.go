@@ -0,0 +1,208 @@
+// Package nina runs the routers of the router package as HTTP servers.
+package nina
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jonecoboy/nina/router"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Default timeouts of the servers built by NewServer.
+const (
+	DefaultReadHeaderTimeout = 5 * time.Second
+	DefaultReadTimeout       = 15 * time.Second
+	DefaultWriteTimeout      = 30 * time.Second
+	DefaultIdleTimeout       = 120 * time.Second
+	DefaultShutdownTimeout   = 30 * time.Second
+)
+
+// Hook runs when the server starts or stops, see OnStart and OnShutdown.
+type Hook func(ctx context.Context) error
+
+// Server serves a router over HTTP, or HTTPS when TLS is set, until its
+// context is done or the process receives SIGINT or SIGTERM, then stops
+// accepting connections and lets the requests in flight finish within
+// ShutdownTimeout.
+type Server struct {
+	// Addr is the TCP address to listen on, ":http" or ":https" when empty
+	Addr    string
+	Handler http.Handler
+
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// ShutdownTimeout is the drain deadline of the requests in flight, the
+	// remaining connections are closed once it is over
+	ShutdownTimeout time.Duration
+	// Signals stop the server, SIGINT and SIGTERM when nil
+	Signals  []os.Signal
+	ErrorLog *log.Logger
+	// TLS serves HTTPS instead of HTTP when set
+	TLS *TLSConfig
+
+	mu         sync.Mutex
+	onStart    []Hook
+	onShutdown []Hook
+	running    bool
+}
+
+// NewServer creates a Server serving mux on addr with the default timeouts.
+func NewServer(addr string, mux *router.ServeMux) *Server {
+	return &Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: DefaultReadHeaderTimeout,
+		ReadTimeout:       DefaultReadTimeout,
+		WriteTimeout:      DefaultWriteTimeout,
+		IdleTimeout:       DefaultIdleTimeout,
+		ShutdownTimeout:   DefaultShutdownTimeout,
+	}
+}
+
+// OnStart adds a hook run before the first connection is accepted, an error
+// stops the server before it serves anything.
+func (s *Server) OnStart(hook Hook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onStart = append(s.onStart, hook)
+}
+
+// OnShutdown adds a hook run once the requests in flight are drained, within
+// the ShutdownTimeout deadline. Hooks run in the reverse order they were
+// added, like deferred calls, and all of them run even when one fails.
+func (s *Server) OnShutdown(hook Hook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onShutdown = append(s.onShutdown, hook)
+}
+
+// Run listens on Addr and serves until ctx is done or a stop signal is
+// received. It returns nil after a graceful shutdown.
+func (s *Server) Run(ctx context.Context) error {
+	addr := s.Addr
+	switch {
+	case addr == "" && s.TLS != nil:
+		addr = ":https"
+	case addr == "":
+		addr = ":http"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve is Run on an existing listener, which is closed when it returns.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		ln.Close()
+		return errors.New("nina: server already running")
+	}
+	s.running = true
+	onStart := append([]Hook(nil), s.onStart...)
+	onShutdown := append([]Hook(nil), s.onShutdown...)
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+
+	var certs *certReloader
+	if s.TLS != nil {
+		var err error
+		if certs, err = newCertReloader(s.TLS, s.ErrorLog); err != nil {
+			ln.Close()
+			return err
+		}
+	}
+
+	signals := s.Signals
+	if signals == nil {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	ctx, stop := signal.NotifyContext(ctx, signals...)
+	defer stop()
+
+	for _, hook := range onStart {
+		if err := hook(ctx); err != nil {
+			ln.Close()
+			return fmt.Errorf("nina: start hook: %w", err)
+		}
+	}
+
+	srv := s.httpServer(ctx)
+	serveErr := make(chan error, 1)
+	if certs != nil {
+		srv.TLSConfig = certs.tlsConfig()
+		go certs.watch(ctx)
+		go func() {
+			serveErr <- srv.ServeTLS(ln, "", "")
+		}()
+	} else {
+		go func() {
+			serveErr <- srv.Serve(ln)
+		}()
+	}
+
+	var err error
+	select {
+	case err = <-serveErr:
+		// The listener failed, nothing left to drain but the hooks still run
+	case <-ctx.Done():
+	}
+	stop()
+
+	// The deadline is not derived from ctx, it is already done
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.shutdownTimeout())
+	defer cancel()
+	if shutdownErr := srv.Shutdown(shutdownCtx); shutdownErr != nil {
+		srv.Close()
+		err = errors.Join(err, fmt.Errorf("nina: drain requests: %w", shutdownErr))
+	}
+
+	for i := len(onShutdown) - 1; i >= 0; i-- {
+		if hookErr := onShutdown[i](shutdownCtx); hookErr != nil {
+			err = errors.Join(err, fmt.Errorf("nina: shutdown hook: %w", hookErr))
+		}
+	}
+	return err
+}
+
+func (s *Server) httpServer(ctx context.Context) *http.Server {
+	// Requests keep the values of ctx but are not cancelled with it, they are
+	// drained by Shutdown
+	base := context.WithoutCancel(ctx)
+	return &http.Server{
+		Addr:              s.Addr,
+		Handler:           s.Handler,
+		ReadHeaderTimeout: s.ReadHeaderTimeout,
+		ReadTimeout:       s.ReadTimeout,
+		WriteTimeout:      s.WriteTimeout,
+		IdleTimeout:       s.IdleTimeout,
+		MaxHeaderBytes:    s.MaxHeaderBytes,
+		ErrorLog:          s.ErrorLog,
+		BaseContext:       func(net.Listener) context.Context { return base },
+	}
+}
+
+func (s *Server) shutdownTimeout() time.Duration {
+	if s.ShutdownTimeout <= 0 {
+		return DefaultShutdownTimeout
+	}
+	return s.ShutdownTimeout
+}
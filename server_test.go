@@ -0,0 +1,215 @@
+package nina
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonecoboy/nina/router"
+)
+
+// startServer serves s on a random port and returns its URL and the result
+// of Serve
+func startServer(t *testing.T, ctx context.Context, s *Server) (string, <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Serve(ctx, ln)
+	}()
+	return "http://" + ln.Addr().String(), done
+}
+
+func TestServerGracefulShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mux := router.NewRouter()
+	mux.GET("/slow", func(w http.ResponseWriter, r *router.NinaRequest) {
+		close(started)
+		<-release
+		w.Write([]byte("drained"))
+	}, nil)
+	mux.GET("/ping", func(w http.ResponseWriter, r *router.NinaRequest) {}, nil)
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string) Hook {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, name)
+			return nil
+		}
+	}
+
+	s := NewServer("", mux)
+	s.OnStart(record("start"))
+	s.OnShutdown(record("close dispatcher"))
+	s.OnShutdown(record("flush logs"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	url, done := startServer(t, ctx, s)
+
+	body := make(chan string, 1)
+	go func() {
+		res, err := http.Get(url + "/slow")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		body <- string(b)
+	}()
+
+	<-started
+	cancel()
+	// New connections are refused once the listener is closed by Shutdown
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}, Timeout: time.Second}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		res, err := client.Get(url + "/ping")
+		if err != nil {
+			break
+		}
+		res.Body.Close()
+		if time.Now().After(deadline) {
+			t.Fatal("new connections should be refused while draining")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(release)
+
+	if got := <-body; got != "drained" {
+		t.Errorf("got in-flight response %q, want drained", got)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("got %v, want a graceful shutdown", err)
+	}
+	if got := strings.Join(calls, ","); got != "start,flush logs,close dispatcher" {
+		t.Errorf("got hooks %v", got)
+	}
+}
+
+func TestServerShutdownErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(s *Server, release chan struct{})
+		request bool
+		wantErr string
+	}{
+		{
+			name: "Drain deadline exceeded",
+			setup: func(s *Server, release chan struct{}) {
+				s.ShutdownTimeout = 50 * time.Millisecond
+			},
+			request: true,
+			wantErr: "drain requests: context deadline exceeded",
+		},
+		{
+			name: "Start hook fails",
+			setup: func(s *Server, release chan struct{}) {
+				s.OnStart(func(ctx context.Context) error { return errors.New("no database") })
+			},
+			wantErr: "start hook: no database",
+		},
+		{
+			name: "Shutdown hooks all run",
+			setup: func(s *Server, release chan struct{}) {
+				s.OnShutdown(func(ctx context.Context) error { close(release); return nil })
+				s.OnShutdown(func(ctx context.Context) error { return errors.New("flush failed") })
+			},
+			wantErr: "shutdown hook: flush failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{}, 1)
+			release := make(chan struct{})
+			mux := router.NewRouter()
+			mux.GET("/slow", func(w http.ResponseWriter, r *router.NinaRequest) {
+				started <- struct{}{}
+				select {
+				case <-release:
+				case <-time.After(time.Second):
+				}
+			}, nil)
+
+			s := NewServer("", mux)
+			tt.setup(s, release)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			url, done := startServer(t, ctx, s)
+
+			if tt.request {
+				go http.Get(url + "/slow")
+				<-started
+			}
+			cancel()
+
+			err := <-done
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServerStopsOnSignal(t *testing.T) {
+	// Keeps the test binary alive if the signal lands outside Serve
+	guard := make(chan os.Signal, 1)
+	signal.Notify(guard, os.Interrupt)
+	defer signal.Stop(guard)
+
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	s := NewServer("", router.NewRouter())
+	s.OnStart(func(ctx context.Context) error { close(started); return nil })
+	s.OnShutdown(func(ctx context.Context) error { close(stopped); return nil })
+	_, done := startServer(t, context.Background(), s)
+
+	// Start hooks run once Serve listens for the stop signals
+	<-started
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := process.Signal(os.Interrupt); err != nil {
+		t.Skipf("cannot send os.Interrupt on this platform: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("got %v, want a graceful shutdown", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("server did not stop on os.Interrupt")
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Error("shutdown hooks did not run")
+	}
+}
+
+func TestNewServerDefaults(t *testing.T) {
+	s := NewServer(":8081", router.NewRouter())
+	if s.ReadHeaderTimeout != DefaultReadHeaderTimeout || s.ReadTimeout != DefaultReadTimeout ||
+		s.WriteTimeout != DefaultWriteTimeout || s.IdleTimeout != DefaultIdleTimeout ||
+		s.ShutdownTimeout != DefaultShutdownTimeout {
+		t.Errorf("got timeouts %+v", s)
+	}
+}
@@ -0,0 +1,204 @@
+package nina
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultReloadInterval is how often the certificate files are checked for
+// changes when TLSConfig.ReloadInterval is 0.
+const DefaultReloadInterval = 10 * time.Second
+
+// TLSConfig serves HTTPS from PEM files. The files are polled for changes and
+// reloaded without a restart, new connections use the new certificate while
+// a renewal that fails to load keeps the previous one.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables mTLS, client certificates are verified against
+	// the PEM certificates it holds and it is reloaded like the key pair
+	ClientCAFile string
+	// ClientAuth is the client certificate policy, RequireAndVerifyClientCert
+	// when ClientCAFile is set and it is left to NoClientCert
+	ClientAuth tls.ClientAuthType
+	// MinVersion is TLS 1.2 when 0
+	MinVersion uint16
+	// ReloadInterval is how often the files are checked, DefaultReloadInterval
+	// when 0, negative disables the reload
+	ReloadInterval time.Duration
+}
+
+// certReloader holds the certificates loaded from a TLSConfig and reloads
+// them when the files change on disk
+type certReloader struct {
+	config  *TLSConfig
+	logger  *log.Logger
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	clients *x509.CertPool
+	stamps  []fileStamp
+}
+
+// fileStamp identifies a version of a file
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func newCertReloader(config *TLSConfig, logger *log.Logger) (*certReloader, error) {
+	if config.CertFile == "" || config.KeyFile == "" {
+		return nil, errors.New("nina: TLS needs a CertFile and a KeyFile")
+	}
+	r := &certReloader{config: config, logger: logger}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) files() []string {
+	files := []string{r.config.CertFile, r.config.KeyFile}
+	if r.config.ClientCAFile != "" {
+		files = append(files, r.config.ClientCAFile)
+	}
+	return files
+}
+
+// stat returns the current version of every file
+func (r *certReloader) stat() ([]fileStamp, error) {
+	files := r.files()
+	stamps := make([]fileStamp, len(files))
+	for i, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		stamps[i] = fileStamp{info.ModTime(), info.Size()}
+	}
+	return stamps, nil
+}
+
+func (r *certReloader) load() error {
+	stamps, err := r.stat()
+	if err != nil {
+		return fmt.Errorf("nina: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("nina: load key pair: %w", err)
+	}
+	var clients *x509.CertPool
+	if r.config.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("nina: %w", err)
+		}
+		clients = x509.NewCertPool()
+		if !clients.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("nina: no certificate found in %s", r.config.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert, r.clients, r.stamps = &cert, clients, stamps
+	return nil
+}
+
+// changed returns the versions of the files when one was modified since the
+// last load, nil otherwise
+func (r *certReloader) changed() []fileStamp {
+	stamps, err := r.stat()
+	if err != nil {
+		// Files being replaced, check again on the next tick
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i := range stamps {
+		if stamps[i] != r.stamps[i] {
+			return stamps
+		}
+	}
+	return nil
+}
+
+// watch polls the files until ctx is done
+func (r *certReloader) watch(ctx context.Context) {
+	interval := r.config.ReloadInterval
+	if interval < 0 {
+		return
+	}
+	if interval == 0 {
+		interval = DefaultReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stamps := r.changed()
+			if stamps == nil {
+				continue
+			}
+			if err := r.load(); err != nil {
+				r.logf("certificate reload failed, keeping the previous one: %v", err)
+				// Retried once the files change again
+				r.mu.Lock()
+				r.stamps = stamps
+				r.mu.Unlock()
+			}
+		}
+	}
+}
+
+func (r *certReloader) logf(format string, args ...interface{}) {
+	if r.logger != nil {
+		r.logger.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// tlsConfig builds the configuration of the listener, certificates are read
+// from the reloader on every handshake
+func (r *certReloader) tlsConfig() *tls.Config {
+	config := &tls.Config{
+		MinVersion: r.config.MinVersion,
+		NextProtos: []string{"h2", "http/1.1"},
+		ClientAuth: r.config.ClientAuth,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.cert, nil
+		},
+	}
+	if config.MinVersion == 0 {
+		config.MinVersion = tls.VersionTLS12
+	}
+	if r.config.ClientCAFile == "" {
+		return config
+	}
+
+	if config.ClientAuth == tls.NoClientCert {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		clientConfig := config.Clone()
+		clientConfig.GetConfigForClient = nil
+		clientConfig.ClientCAs = r.clients
+		return clientConfig, nil
+	}
+	return config
+}
@@ -0,0 +1,190 @@
+package nina
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonecoboy/nina/internal/testcert"
+	"github.com/jonecoboy/nina/router"
+)
+
+// serverCert issues a certificate for the test servers signed by ca
+func serverCert(t *testing.T, cn string, ca *testcert.Cert) *testcert.Cert {
+	t.Helper()
+	return testcert.New(t, ca, cn, "localhost", "127.0.0.1")
+}
+
+// syncBuffer collects the server logs written while the test reads them
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func startTLSServer(t *testing.T, config *TLSConfig, errorLog *log.Logger) string {
+	t.Helper()
+	mux := router.NewRouter()
+	mux.GET("/whoami", func(w http.ResponseWriter, r *router.NinaRequest) {
+		if certs := r.PeerCertificates(); len(certs) > 0 {
+			io.WriteString(w, certs[0].Subject.CommonName)
+			return
+		}
+		io.WriteString(w, "anonymous")
+	}, nil)
+
+	s := NewServer("", mux)
+	s.TLS = config
+	s.ErrorLog = errorLog
+	ctx, cancel := context.WithCancel(context.Background())
+	url, done := startServer(t, ctx, s)
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return "https" + url[len("http"):]
+}
+
+func tlsClient(ca *testcert.Cert, clientCert *testcert.Cert) *http.Client {
+	config := &tls.Config{RootCAs: ca.Pool()}
+	if clientCert != nil {
+		config.Certificates = []tls.Certificate{clientCert.TLS()}
+	}
+	// A new transport per client so every request makes a new handshake
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: config}, Timeout: 5 * time.Second}
+}
+
+func get(client *http.Client, url string) (string, *tls.ConnectionState, error) {
+	res, err := client.Get(url)
+	if err != nil {
+		return "", nil, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	return string(body), res.TLS, err
+}
+
+func TestServerTLSReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := testcert.New(t, nil, "test ca")
+	certFile, keyFile := serverCert(t, "first", ca).Write(t, dir, "server")
+
+	logs := &syncBuffer{}
+	url := startTLSServer(t, &TLSConfig{CertFile: certFile, KeyFile: keyFile, ReloadInterval: 10 * time.Millisecond}, log.New(logs, "", 0))
+
+	body, state, err := get(tlsClient(ca, nil), url+"/whoami")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "anonymous" || state.PeerCertificates[0].Subject.CommonName != "first" {
+		t.Errorf("got %v from %v", body, state.PeerCertificates[0].Subject.CommonName)
+	}
+
+	// A broken renewal keeps the previous certificate
+	testcert.WriteFile(t, certFile, []byte("not a certificate"))
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(logs.String(), "certificate reload failed") {
+		if time.Now().After(deadline) {
+			t.Fatal("broken certificate never reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, state, err := get(tlsClient(ca, nil), url+"/whoami"); err != nil || state.PeerCertificates[0].Subject.CommonName != "first" {
+		t.Errorf("got %v, want the previous certificate kept", err)
+	}
+
+	serverCert(t, "second", ca).Write(t, dir, "server")
+	deadline = time.Now().Add(2 * time.Second)
+	for {
+		_, state, err := get(tlsClient(ca, nil), url+"/whoami")
+		if err == nil && state.PeerCertificates[0].Subject.CommonName == "second" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("certificate not reloaded: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := testcert.New(t, nil, "test ca")
+	otherCA := testcert.New(t, nil, "other ca")
+	certFile, keyFile := serverCert(t, "server", ca).Write(t, dir, "server")
+	caFile, _ := ca.Write(t, dir, "ca")
+
+	url := startTLSServer(t, &TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile}, nil)
+
+	tests := []struct {
+		name     string
+		client   *testcert.Cert
+		wantBody string
+		wantErr  bool
+	}{
+		{"Verified client", serverCert(t, "billing", ca), "billing", false},
+		{"No client certificate", nil, "", true},
+		{"Unknown issuer", serverCert(t, "intruder", otherCA), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _, err := get(tlsClient(ca, tt.client), url+"/whoami")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if body != tt.wantBody {
+				t.Errorf("got %v, want %v", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestServerTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	ca := testcert.New(t, nil, "test ca")
+	certFile, keyFile := serverCert(t, "server", ca).Write(t, dir, "server")
+
+	tests := []struct {
+		name   string
+		config *TLSConfig
+	}{
+		{"Missing key file", &TLSConfig{CertFile: certFile}},
+		{"Unreadable key pair", &TLSConfig{CertFile: certFile, KeyFile: filepath.Join(dir, "missing.key")}},
+		{"Empty client CA", &TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer("", router.NewRouter())
+			s.TLS = tt.config
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Serve(context.Background(), ln); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}